@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/migrations"
+)
+
+const copyUsage = "usage: shortener copy --from-st file|sqlite|postgres [--from-f PATH] [--from-dsn DSN] " +
+	"--to-st file|sqlite|postgres [--to-f PATH] [--to-dsn DSN] [--batch N] [--checkpoint PATH]"
+
+const (
+	defaultCopyBatchSize  = 1000
+	defaultCopyCheckpoint = "shortener-copy.checkpoint"
+)
+
+// copyCheckpoint is the progress of a copy, saved after every batch so that
+// an interrupted copy resumes after the last stored batch.
+type copyCheckpoint struct {
+	// Source and Target identify the storages, so that a checkpoint is not
+	// resumed by a different copy.
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Cursor is the short URL of the last source record stored.
+	Cursor string `json:"cursor"`
+	Copied int    `json:"copied"`
+	// Skipped counts the source records that conflict with the target.
+	Skipped int `json:"skipped"`
+	// TargetBefore is the number of records the target held before the copy.
+	TargetBefore int `json:"target_before"`
+}
+
+type copyOptions struct {
+	batchSize      int
+	checkpointPath string
+}
+
+// copyEndpoint is one side of a copy: a storage type and where it lives.
+type copyEndpoint struct {
+	storageType string
+	filePath    string
+	dsn         string
+}
+
+func (e copyEndpoint) String() string {
+	if e.storageType == "file" {
+		return "file:" + e.filePath
+	}
+	return e.storageType + ":" + e.dsn
+}
+
+// runCopy copies every link of one storage into another, without starting
+// the HTTP server:
+//
+//	./shortener copy --from-st file --from-f records.json --to-st postgres --to-dsn "host=localhost ..."
+func runCopy(args []string) error {
+	var (
+		from, to copyEndpoint
+		opts     copyOptions
+	)
+	flags := flag.NewFlagSet("copy", flag.ContinueOnError)
+	flags.StringVar(&from.storageType, "from-st", "", "Source storage type (file|sqlite|postgres)")
+	flags.StringVar(&from.filePath, "from-f", "", "Source file storage path")
+	flags.StringVar(&from.dsn, "from-dsn", "", "Source database connection string")
+	flags.StringVar(&to.storageType, "to-st", "", "Target storage type (file|sqlite|postgres)")
+	flags.StringVar(&to.filePath, "to-f", "", "Target file storage path")
+	flags.StringVar(&to.dsn, "to-dsn", "", "Target database connection string")
+	flags.IntVar(&opts.batchSize, "batch", defaultCopyBatchSize, "Records written per batch")
+	flags.StringVar(&opts.checkpointPath, "checkpoint", defaultCopyCheckpoint, "Progress file used to resume an interrupted copy")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(copyUsage)
+	}
+	if flags.NArg() > 0 || opts.batchSize <= 0 {
+		return errors.New(copyUsage)
+	}
+
+	source, err := openCopyRepository(from)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	target, err := openCopyRepository(to)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+
+	return copyURLs(context.Background(), source, target, from.String(), to.String(), opts, os.Stdout)
+}
+
+// openCopyRepository opens a storage the way the server would, applying
+// pending migrations to databases.
+func openCopyRepository(endpoint copyEndpoint) (repo.IURLRepository, error) {
+	cfg := &config.Config{
+		StorageType:     endpoint.storageType,
+		DatabaseType:    endpoint.storageType,
+		FileStoragePath: endpoint.filePath,
+		DatabaseDSN:     endpoint.dsn,
+	}
+	switch {
+	case endpoint.storageType == "file" && endpoint.filePath == "":
+		return nil, errors.New("file storage requires a path")
+	case (endpoint.storageType == "sqlite" || endpoint.storageType == "postgres") && endpoint.dsn == "":
+		return nil, fmt.Errorf("%s storage requires a DSN", endpoint.storageType)
+	case endpoint.storageType != "file" && endpoint.storageType != "sqlite" && endpoint.storageType != "postgres":
+		return nil, fmt.Errorf("cannot copy %q storage. %s", endpoint.storageType, copyUsage)
+	}
+
+	db, err := repo.NewDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrations.Run(cfg, db); err != nil {
+		return nil, err
+	}
+
+	urlRepo := provideRepository(cfg, db)
+	if err := urlRepo.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+	return urlRepo, nil
+}
+
+// copyURLs copies every record of source into target in batches, resuming
+// from the checkpoint when there is one, then verifies the record counts.
+// The checkpoint is removed once the copy is verified.
+func copyURLs(
+	ctx context.Context, source, target repo.IURLRepository, sourceName, targetName string, opts copyOptions, w io.Writer,
+) error {
+	checkpoint, resumed, err := loadCopyCheckpoint(opts.checkpointPath)
+	if err != nil {
+		return err
+	}
+	if resumed {
+		if checkpoint.Source != sourceName || checkpoint.Target != targetName {
+			return fmt.Errorf("checkpoint %s belongs to a copy from %s to %s; remove it to start over",
+				opts.checkpointPath, checkpoint.Source, checkpoint.Target)
+		}
+		fmt.Fprintf(w, "resuming after %q: %d records copied, %d skipped\n",
+			checkpoint.Cursor, checkpoint.Copied, checkpoint.Skipped)
+	} else {
+		checkpoint = copyCheckpoint{Source: sourceName, Target: targetName}
+		if checkpoint.TargetBefore, err = countURLs(ctx, target, opts.batchSize); err != nil {
+			return fmt.Errorf("could not count target records: %w", err)
+		}
+	}
+
+	it := repo.NewURLIteratorAfter(source, opts.batchSize, checkpoint.Cursor)
+	batch := make([]dto.URLRecord, 0, opts.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		skipped, err := copyBatch(ctx, target, batch, checkpoint.Cursor, w)
+		if err != nil {
+			return err
+		}
+
+		checkpoint.Cursor = batch[len(batch)-1].ShortURL
+		checkpoint.Copied += len(batch) - skipped
+		checkpoint.Skipped += skipped
+		batch = batch[:0]
+		return saveCopyCheckpoint(opts.checkpointPath, checkpoint)
+	}
+
+	for it.Next(ctx) {
+		batch = append(batch, it.Record())
+		if len(batch) == opts.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("could not read source records: %w", err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "copied %d records, skipped %d\n", checkpoint.Copied, checkpoint.Skipped)
+
+	if err := verifyCopy(ctx, source, target, checkpoint, opts.batchSize, w); err != nil {
+		return fmt.Errorf("%w; checkpoint kept at %s", err, opts.checkpointPath)
+	}
+	if err := os.Remove(opts.checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// copyBatch stores a batch, whose records sort after the short URL after, in
+// target. Records that conflict with the target are reported and left out,
+// and the rest of the batch is retried. It returns the number of records
+// left out. A record whose short ID the target already holds for the same
+// original URL is not a conflict: it was copied by a run that stopped before
+// saving its checkpoint.
+func copyBatch(
+	ctx context.Context, target repo.IURLRepository, batch []dto.URLRecord, after string, w io.Writer,
+) (int, error) {
+	records := append([]dto.URLRecord(nil), batch...)
+	skipped := 0
+	// stored holds the target records in the range of the batch, read on the
+	// first taken short ID.
+	var stored map[string]dto.URLRecord
+	for len(records) > 0 {
+		saved, err := target.SaveURLBatch(ctx, records)
+		var itemErr *repo.BatchItemError
+		if errors.As(err, &itemErr) && errors.Is(err, repo.ErrShortIDTaken) && itemErr.Index < len(records) {
+			if stored == nil {
+				if stored, err = rangeURLs(ctx, target, after, batch[len(batch)-1].ShortURL, len(batch)); err != nil {
+					return 0, fmt.Errorf("could not read target records: %w", err)
+				}
+			}
+			record := records[itemErr.Index]
+			if existing, ok := stored[record.ShortURL]; !ok || existing.OriginalURL != record.OriginalURL {
+				fmt.Fprintf(w, "skipped %s (%s): short ID is taken in the target\n", record.ShortURL, record.OriginalURL)
+				skipped++
+			}
+			records = append(records[:itemErr.Index], records[itemErr.Index+1:]...)
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not store batch: %w", err)
+		}
+
+		for i, record := range saved {
+			if record.ShortURL != records[i].ShortURL {
+				fmt.Fprintf(w, "skipped %s (%s): original URL is already stored as %s in the target\n",
+					records[i].ShortURL, records[i].OriginalURL, record.ShortURL)
+				skipped++
+			}
+		}
+		break
+	}
+	return skipped, nil
+}
+
+// verifyCopy checks that the target gained one record per source record
+// that was not skipped. It assumes nothing else wrote to either storage
+// during the copy.
+func verifyCopy(
+	ctx context.Context, source, target repo.IURLRepository, checkpoint copyCheckpoint, pageSize int, w io.Writer,
+) error {
+	sourceCount, err := countURLs(ctx, source, pageSize)
+	if err != nil {
+		return fmt.Errorf("could not count source records: %w", err)
+	}
+	targetCount, err := countURLs(ctx, target, pageSize)
+	if err != nil {
+		return fmt.Errorf("could not count target records: %w", err)
+	}
+
+	added := targetCount - checkpoint.TargetBefore
+	fmt.Fprintf(w, "verification: source has %d records, target gained %d (%d before, %d now), %d skipped\n",
+		sourceCount, added, checkpoint.TargetBefore, targetCount, checkpoint.Skipped)
+	if added != sourceCount-checkpoint.Skipped {
+		return fmt.Errorf("verification failed: expected the target to gain %d records, it gained %d",
+			sourceCount-checkpoint.Skipped, added)
+	}
+	return nil
+}
+
+// rangeURLs returns the records of urlRepo whose short URLs sort after after
+// and up to last, by short URL.
+func rangeURLs(
+	ctx context.Context, urlRepo repo.IURLRepository, after, last string, pageSize int,
+) (map[string]dto.URLRecord, error) {
+	records := make(map[string]dto.URLRecord)
+	it := repo.NewURLIteratorAfter(urlRepo, pageSize, after)
+	for it.Next(ctx) && it.Record().ShortURL <= last {
+		records[it.Record().ShortURL] = it.Record()
+	}
+	return records, it.Err()
+}
+
+func countURLs(ctx context.Context, urlRepo repo.IURLRepository, pageSize int) (int, error) {
+	n := 0
+	it := repo.NewURLIterator(urlRepo, pageSize)
+	for it.Next(ctx) {
+		n++
+	}
+	return n, it.Err()
+}
+
+func loadCopyCheckpoint(path string) (copyCheckpoint, bool, error) {
+	var checkpoint copyCheckpoint
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, false, nil
+	}
+	if err != nil {
+		return checkpoint, false, fmt.Errorf("could not read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, false, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return checkpoint, true, nil
+}
+
+// saveCopyCheckpoint replaces the checkpoint atomically, so that a crash
+// leaves either the previous checkpoint or the new one.
+func saveCopyCheckpoint(path string, checkpoint copyCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepository fails SaveURLBatch once it has stored failAfter batches.
+// With storeFailed, the failing batch is stored first, as when the
+// connection is lost after the commit.
+type failingRepository struct {
+	repo.IURLRepository
+	failAfter   int
+	storeFailed bool
+	batches     int
+}
+
+func (r *failingRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	if r.batches == r.failAfter {
+		if r.storeFailed {
+			if _, err := r.IURLRepository.SaveURLBatch(ctx, records); err != nil {
+				return nil, err
+			}
+		}
+		return nil, errors.New("connection lost")
+	}
+	r.batches++
+	return r.IURLRepository.SaveURLBatch(ctx, records)
+}
+
+func seedRecords(t *testing.T, urlRepo repo.IURLRepository, n int) []dto.URLRecord {
+	records := make([]dto.URLRecord, n)
+	for i := range records {
+		records[i] = dto.URLRecord{
+			ShortURL:    fmt.Sprintf("id%03d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user",
+			IsDeleted:   i%3 == 0,
+			CreatedAt:   time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+		}
+		if i%2 == 0 {
+			records[i].ExpiresAt = time.Date(2030, 1, 1, 0, i, 0, 0, time.UTC)
+		}
+	}
+	_, err := urlRepo.SaveURLBatch(context.Background(), records)
+	require.NoError(t, err)
+	return records
+}
+
+func listAll(t *testing.T, urlRepo repo.IURLRepository) []dto.URLRecord {
+	var records []dto.URLRecord
+	it := repo.NewURLIterator(urlRepo, 10)
+	for it.Next(context.Background()) {
+		records = append(records, it.Record())
+	}
+	require.NoError(t, it.Err())
+	return records
+}
+
+func TestCopyURLs(t *testing.T) {
+	tests := []struct {
+		name            string
+		targetRecords   []dto.URLRecord
+		expectedSkipped []string
+		expectedCount   int
+	}{
+		{
+			name:          "Empty target",
+			expectedCount: 25,
+		},
+		{
+			name: "Target with unrelated records",
+			targetRecords: []dto.URLRecord{
+				{ShortURL: "other", OriginalURL: "https://other.example.com"},
+			},
+			expectedCount: 26,
+		},
+		{
+			name: "Conflicting records are skipped",
+			targetRecords: []dto.URLRecord{
+				{ShortURL: "id004", OriginalURL: "https://taken.example.com"},
+				{ShortURL: "kept", OriginalURL: "https://example.com/7"},
+			},
+			expectedSkipped: []string{"skipped id004", "skipped id007"},
+			expectedCount:   25,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := memory.NewMemoryRepository()
+			target := memory.NewMemoryRepository()
+			records := seedRecords(t, source, 25)
+			if len(test.targetRecords) > 0 {
+				_, err := target.SaveURLBatch(context.Background(), test.targetRecords)
+				require.NoError(t, err)
+			}
+			opts := copyOptions{batchSize: 10, checkpointPath: filepath.Join(t.TempDir(), "copy.checkpoint")}
+
+			var out bytes.Buffer
+			err := copyURLs(context.Background(), source, target, "source", "target", opts, &out)
+
+			require.NoError(t, err)
+			for _, skipped := range test.expectedSkipped {
+				assert.Contains(t, out.String(), skipped)
+			}
+			assert.Contains(t, out.String(), fmt.Sprintf("skipped %d\n", len(test.expectedSkipped)))
+			assert.Len(t, listAll(t, target), test.expectedCount)
+			assert.NoFileExists(t, opts.checkpointPath)
+
+			copied := make(map[string]dto.URLRecord)
+			for _, record := range listAll(t, target) {
+				copied[record.ShortURL] = record
+			}
+			for _, record := range records {
+				if record.ShortURL == "id004" || record.ShortURL == "id007" {
+					continue
+				}
+				assert.Equal(t, record.OriginalURL, copied[record.ShortURL].OriginalURL)
+				assert.Equal(t, record.IsDeleted, copied[record.ShortURL].IsDeleted)
+				assert.Equal(t, record.CreatedAt, copied[record.ShortURL].CreatedAt)
+				assert.Equal(t, record.ExpiresAt, copied[record.ShortURL].ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestCopyURLsResume(t *testing.T) {
+	source := memory.NewMemoryRepository()
+	target := memory.NewMemoryRepository()
+	seedRecords(t, source, 25)
+	opts := copyOptions{batchSize: 10, checkpointPath: filepath.Join(t.TempDir(), "copy.checkpoint")}
+
+	var out bytes.Buffer
+	failing := &failingRepository{IURLRepository: target, failAfter: 2}
+	err := copyURLs(context.Background(), source, failing, "source", "target", opts, &out)
+	require.Error(t, err)
+	require.FileExists(t, opts.checkpointPath)
+
+	checkpoint, resumed, err := loadCopyCheckpoint(opts.checkpointPath)
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, "id019", checkpoint.Cursor)
+	assert.Equal(t, 20, checkpoint.Copied)
+
+	out.Reset()
+	err = copyURLs(context.Background(), source, target, "source", "target", opts, &out)
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `resuming after "id019"`)
+	assert.Contains(t, out.String(), "copied 25 records, skipped 0")
+	assert.Len(t, listAll(t, target), 25)
+	assert.NoFileExists(t, opts.checkpointPath)
+}
+
+func TestCopyURLsResumeAfterStoredBatch(t *testing.T) {
+	source := memory.NewMemoryRepository()
+	target := memory.NewMemoryRepository()
+	seedRecords(t, source, 25)
+	opts := copyOptions{batchSize: 10, checkpointPath: filepath.Join(t.TempDir(), "copy.checkpoint")}
+
+	var out bytes.Buffer
+	failing := &failingRepository{IURLRepository: target, failAfter: 1, storeFailed: true}
+	err := copyURLs(context.Background(), source, failing, "source", "target", opts, &out)
+	require.Error(t, err)
+	assert.Len(t, listAll(t, target), 20, "the second batch is stored without its checkpoint")
+
+	// The second batch, deleted records included, is found already copied.
+	out.Reset()
+	err = copyURLs(context.Background(), source, target, "source", "target", opts, &out)
+
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "skipped id")
+	assert.Contains(t, out.String(), "copied 25 records, skipped 0")
+	assert.Len(t, listAll(t, target), 25)
+	assert.NoFileExists(t, opts.checkpointPath)
+}
+
+func TestCopyURLsCheckpointMismatch(t *testing.T) {
+	opts := copyOptions{batchSize: 10, checkpointPath: filepath.Join(t.TempDir(), "copy.checkpoint")}
+	require.NoError(t, saveCopyCheckpoint(opts.checkpointPath, copyCheckpoint{Source: "other", Target: "target"}))
+
+	err := copyURLs(context.Background(), memory.NewMemoryRepository(), memory.NewMemoryRepository(),
+		"source", "target", opts, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "belongs to a copy from other to target")
+}
+
+func TestCopyURLsVerificationFailure(t *testing.T) {
+	source := memory.NewMemoryRepository()
+	target := memory.NewMemoryRepository()
+	seedRecords(t, source, 5)
+	opts := copyOptions{batchSize: 10, checkpointPath: filepath.Join(t.TempDir(), "copy.checkpoint")}
+	// A checkpoint that claims the target held more records than it did.
+	require.NoError(t, saveCopyCheckpoint(opts.checkpointPath,
+		copyCheckpoint{Source: "source", Target: "target", TargetBefore: 1}))
+
+	err := copyURLs(context.Background(), source, target, "source", "target", opts, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "verification failed")
+	assert.FileExists(t, opts.checkpointPath)
+}
+
+func TestRunCopy(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "records.json")
+	targetDSN := "file:" + filepath.Join(dir, "target.db")
+	checkpointPath := filepath.Join(dir, "copy.checkpoint")
+
+	source, err := openCopyRepository(copyEndpoint{storageType: "file", filePath: sourcePath})
+	require.NoError(t, err)
+	seedRecords(t, source, 5)
+
+	err = runCopy([]string{
+		"--from-st", "file", "--from-f", sourcePath,
+		"--to-st", "sqlite", "--to-dsn", targetDSN,
+		"--checkpoint", checkpointPath,
+	})
+	require.NoError(t, err)
+
+	target, err := openCopyRepository(copyEndpoint{storageType: "sqlite", dsn: targetDSN})
+	require.NoError(t, err)
+	copied := listAll(t, target)
+	require.Len(t, copied, 5)
+	assert.True(t, copied[0].IsDeleted)
+	assert.False(t, copied[1].IsDeleted)
+
+	assert.NoFileExists(t, checkpointPath)
+}
+
+func TestRunCopyInvalidArguments(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "Unknown flag",
+			args:          []string{"--sideways"},
+			expectedError: "usage",
+		},
+		{
+			name:          "Memory storage",
+			args:          []string{"--from-st", "memory", "--to-st", "file", "--to-f", "x.json"},
+			expectedError: `cannot copy "memory" storage`,
+		},
+		{
+			name:          "Missing DSN",
+			args:          []string{"--from-st", "postgres", "--to-st", "file", "--to-f", "x.json"},
+			expectedError: "postgres storage requires a DSN",
+		},
+		{
+			name:          "Invalid batch size",
+			args:          []string{"--batch", "0"},
+			expectedError: "usage",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runCopy(test.args)
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
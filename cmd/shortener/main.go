@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
 	"github.com/VladimirAzanza/url-shortener/config"
 	_ "github.com/VladimirAzanza/url-shortener/docs"
 	"github.com/VladimirAzanza/url-shortener/internal/controller"
 	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/cache"
 	filerepo "github.com/VladimirAzanza/url-shortener/internal/repo/file_repo"
 	"github.com/VladimirAzanza/url-shortener/internal/repo/memory"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/migrations"
 	"github.com/VladimirAzanza/url-shortener/internal/repo/postgres"
 	"github.com/VladimirAzanza/url-shortener/internal/repo/sqlite"
 	"github.com/VladimirAzanza/url-shortener/internal/server"
 	"github.com/VladimirAzanza/url-shortener/internal/services"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
 	"go.uber.org/fx"
 )
 
@@ -23,45 +31,86 @@ import (
 // @host localhost:8080
 // @BasePath /
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "copy" {
+		if err := runCopy(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fx.New(Module).Run()
 }
 
 var Module = fx.Module(
 	"main",
-	fx.Supply(config.NewConfig()),
 	fx.Provide(
+		config.NewConfig,
 		repo.NewDB,
 		provideRepository,
+		services.NewURLDeleter,
+		services.NewURLJanitor,
+		services.NewClickRecorder,
+		services.NewIDGenerator,
 		services.NewURLService,
+		controller.NewLinkBuilder,
 		controller.NewFiberURLController,
 		server.NewFiberServer,
 	),
-	fx.Invoke(server.StartFiberServer),
+	fx.Invoke(
+		migrations.Run,
+		services.SeedIDGenerator,
+		logCacheStats,
+		server.StartFiberServer,
+	),
 )
 
-func provideRepository(cfg *config.Config) repo.IURLRepository {
+func provideRepository(cfg *config.Config, db *sql.DB) repo.IURLRepository {
+	var urlRepo repo.IURLRepository
 	switch cfg.StorageType {
+	// Both serve lookups from memory already: a cache would only hold a
+	// second copy.
 	case "memory":
 		return memory.NewMemoryRepository()
 	case "file":
 		return filerepo.NewFileRepository(cfg)
 	case "sqlite":
-		db, _ := repo.NewDB(cfg)
-		return sqlite.NewSQLiteRepository(db)
+		urlRepo = sqlite.NewSQLiteRepository(db)
 	case "postgres":
-		db, _ := repo.NewDB(cfg)
-		return postgres.NewPostgreSQLRepository(db)
+		urlRepo = postgres.NewPostgreSQLRepository(db)
 	default:
 		panic("unsupported storage type")
 	}
-}
 
-// Agregar tests de benchmarking
-// Intentar usar errors is errores as y join => revisar increment 13
-// agregar autentificacion con cookies con id unico para user (*http.Request).Cookie() http.SetCookie()
+	if cfg.CacheSize == 0 {
+		return urlRepo
+	}
+	return cache.NewCachedRepository(urlRepo, cfg.CacheSize, cfg.CacheTTL, cfg.CacheNegativeTTL)
+}
 
-// DELETE /api/user/urls 202 Accepted
-// ["6qxTVvsy", "RTfd56hn", "Jlfd67ds"]  <- input
-// Cuando se intenta ingresar a un link que ya no existe -> 410 Gone cuando Get /{id}
-// Para configurar eficazmente el indicador de eliminación en la base de datos, utilice la
-// actualización por lotes. Utilizar el bufer de objetos de actualizacion con patron fan in
+// logCacheStats logs the lookup cache counters on shutdown, when there is a
+// cache.
+func logCacheStats(lc fx.Lifecycle, urlRepo repo.IURLRepository) {
+	cachedRepo, ok := urlRepo.(*cache.CachedRepository)
+	if !ok {
+		return
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			stats := cachedRepo.Stats()
+			log.Info().
+				Uint64("hits", stats.Hits).
+				Uint64("misses", stats.Misses).
+				Int("entries", stats.Entries).
+				Msg("Lookup cache stats")
+			return nil
+		},
+	})
+}
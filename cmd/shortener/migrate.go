@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/migrations"
+)
+
+const migrateUsage = "usage: shortener migrate [-st sqlite|postgres] [-dsn DSN] up|down|status|goto N"
+
+// runMigrate manages the database schema without starting the HTTP server:
+//
+//	./shortener migrate -st postgres -dsn "host=localhost ..." status
+//	./shortener migrate goto 1
+func runMigrate(args []string) error {
+	cfg := config.NewConfigFromArgs(args)
+	command := args
+	if flag.Parsed() {
+		command = flag.Args()
+	}
+	if err := checkMigrateCommand(command); err != nil {
+		return err
+	}
+
+	db, err := repo.NewDB(cfg)
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		return fmt.Errorf("migrations require sqlite or postgres storage, got %q", cfg.StorageType)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, cfg.StorageType)
+	if err != nil {
+		return err
+	}
+
+	return executeMigrateCommand(context.Background(), migrator, os.Stdout, command)
+}
+
+// checkMigrateCommand rejects malformed commands before the database is
+// opened. Flag parsing stops at the command name, so flags placed after it
+// would otherwise be silently ignored.
+func checkMigrateCommand(command []string) error {
+	if len(command) == 0 {
+		return errors.New(migrateUsage)
+	}
+	for _, arg := range command {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("flag %s must come before the command. %s", arg, migrateUsage)
+		}
+	}
+	if command[0] != "goto" && len(command) != 1 {
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+func executeMigrateCommand(ctx context.Context, migrator *migrations.Migrator, w io.Writer, command []string) error {
+	if err := checkMigrateCommand(command); err != nil {
+		return err
+	}
+
+	var err error
+	switch command[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "goto":
+		if len(command) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(command[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid migration version %q: %w", command[1], convErr)
+		}
+		err = migrator.Goto(ctx, version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return printMigrationStatus(w, statuses)
+}
+
+func printMigrationStatus(w io.Writer, statuses []migrations.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return tw.Flush()
+}
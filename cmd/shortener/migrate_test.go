@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/internal/repo/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestMigrator(t *testing.T) *migrations.Migrator {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+	return migrator
+}
+
+func TestExecuteMigrateCommand(t *testing.T) {
+	tests := []struct {
+		name          string
+		commands      [][]string
+		expectedError bool
+		expectedLines []string
+	}{
+		{
+			name:          "Status on empty database",
+			commands:      [][]string{{"status"}},
+			expectedLines: []string{`0001\s+create_short_urls\s+pending`},
+		},
+		{
+			name:          "Up",
+			commands:      [][]string{{"up"}},
+			expectedLines: []string{`0001\s+create_short_urls\s+applied`, `0002\s+add_owner_and_deleted_flag\s+applied`},
+		},
+		{
+			name:          "Goto and down",
+			commands:      [][]string{{"goto", "2"}, {"down"}},
+			expectedLines: []string{`0001\s+create_short_urls\s+applied`, `0002\s+add_owner_and_deleted_flag\s+pending`},
+		},
+		{
+			name:          "Missing command",
+			commands:      [][]string{{}},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown command",
+			commands:      [][]string{{"sideways"}},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid goto version",
+			commands:      [][]string{{"goto", "latest"}},
+			expectedError: true,
+		},
+		{
+			name:          "Flags after the command",
+			commands:      [][]string{{"status", "-st", "postgres"}},
+			expectedError: true,
+		},
+		{
+			name:          "Extra arguments",
+			commands:      [][]string{{"up", "2"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := setupTestMigrator(t)
+
+			var out bytes.Buffer
+			var err error
+			for _, command := range tt.commands {
+				out.Reset()
+				err = executeMigrateCommand(context.Background(), migrator, &out, command)
+			}
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, out.String(), "VERSION")
+			for _, line := range tt.expectedLines {
+				assert.Regexp(t, line, out.String())
+			}
+		})
+	}
+}
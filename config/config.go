@@ -1,10 +1,27 @@
 package config
 
 import (
+	"crypto/rand"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultIDAlphabet is the base62 alphabet used for short IDs.
+const DefaultIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Defaults of the link cache. A zero size disables it, so these are set
+// before the environment and flags are read rather than in setDefaults.
+const (
+	DefaultCacheSize        = 10000
+	DefaultCacheTTL         = time.Minute
+	DefaultCacheNegativeTTL = 10 * time.Second
 )
 
 type Config struct {
@@ -14,13 +31,36 @@ type Config struct {
 	DatabaseType    string `env:"DATABASE_TYPE"`
 	DatabaseDSN     string `env:"DATABASE_DSN"`
 	StorageType     string `env:"STORAGE_TYPE"`
+	SecretKey       string `env:"SECRET_KEY"`
+	IDStrategy      string `env:"ID_STRATEGY"`
+	IDLength        int    `env:"ID_LENGTH"`
+	IDAlphabet      string `env:"ID_ALPHABET"`
+	IDSalt          string `env:"ID_SALT"`
+	NodeID          int    `env:"NODE_ID"`
+	AdminToken      string `env:"ADMIN_TOKEN"`
+	TrustedProxies  string `env:"TRUSTED_PROXIES"`
+
+	CacheSize        int           `env:"CACHE_SIZE"`
+	CacheTTL         time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
 }
 
 func NewConfig() *Config {
-	cfg := &Config{}
+	return NewConfigFromArgs(os.Args[1:])
+}
+
+// NewConfigFromArgs builds the configuration from the environment and the
+// given command-line arguments. Flags are registered on flag.CommandLine, so
+// it must be called only once per process.
+func NewConfigFromArgs(args []string) *Config {
+	cfg := &Config{
+		CacheSize:        DefaultCacheSize,
+		CacheTTL:         DefaultCacheTTL,
+		CacheNegativeTTL: DefaultCacheNegativeTTL,
+	}
 
 	cfg.loadFromEnv()
-	cfg.parseFlags()
+	cfg.parseFlags(args)
 	cfg.setDefaults()
 
 	cfg.validate()
@@ -28,12 +68,12 @@ func NewConfig() *Config {
 	return cfg
 }
 
-func (c *Config) parseFlags() {
+func (c *Config) parseFlags(args []string) {
 	flag.StringVar(
 		&c.ServerAddress, "a", c.ServerAddress, "Server address (env: SERVER_ADDRESS)",
 	)
 	flag.StringVar(
-		&c.BaseURL, "b", c.BaseURL, "Server address (env: BASE_URL)",
+		&c.BaseURL, "b", c.BaseURL, "Public base URL of the short links, may include a path prefix (env: BASE_URL)",
 	)
 	flag.StringVar(
 		&c.FileStoragePath, "f", c.FileStoragePath, "File storage path (env: FILE_STORAGE_PATH)",
@@ -47,13 +87,49 @@ func (c *Config) parseFlags() {
 	flag.StringVar(
 		&c.StorageType, "st", c.StorageType, "Storage type (memory|file|sqlite|postgres) (env: STORAGE_TYPE)",
 	)
-	if hasFlags() {
-		flag.Parse()
+	flag.StringVar(
+		&c.SecretKey, "k", c.SecretKey, "Secret key used to sign user cookies (env: SECRET_KEY)",
+	)
+	flag.StringVar(
+		&c.IDStrategy, "ids", c.IDStrategy, "Short ID strategy (random|counter|hash|snowflake) (env: ID_STRATEGY)",
+	)
+	flag.IntVar(
+		&c.IDLength, "idl", c.IDLength, "Short ID length (env: ID_LENGTH)",
+	)
+	flag.StringVar(
+		&c.IDAlphabet, "ida", c.IDAlphabet, "Short ID alphabet (env: ID_ALPHABET)",
+	)
+	flag.StringVar(
+		&c.IDSalt, "idsalt", c.IDSalt, "Salt of the hash ID strategy (env: ID_SALT)",
+	)
+	flag.IntVar(
+		&c.NodeID, "node", c.NodeID, "Node ID of the snowflake ID strategy, 0-1023 (env: NODE_ID)",
+	)
+	flag.StringVar(
+		&c.AdminToken, "at", c.AdminToken, "Bearer token of the admin endpoints, disabled when empty (env: ADMIN_TOKEN)",
+	)
+	flag.StringVar(
+		&c.TrustedProxies, "tp", c.TrustedProxies,
+		"Comma-separated IPs or CIDRs of the proxies whose X-Forwarded-* headers are trusted (env: TRUSTED_PROXIES)",
+	)
+	flag.IntVar(
+		&c.CacheSize, "cs", c.CacheSize, "Number of links kept in the lookup cache, 0 disables it (env: CACHE_SIZE)",
+	)
+	flag.DurationVar(
+		&c.CacheTTL, "ct", c.CacheTTL, "Lifetime of cached links (env: CACHE_TTL)",
+	)
+	flag.DurationVar(
+		&c.CacheNegativeTTL, "cnt", c.CacheNegativeTTL,
+		"Lifetime of cached misses, 0 disables them (env: CACHE_NEGATIVE_TTL)",
+	)
+	if hasFlags(args) {
+		flag.CommandLine.Parse(args)
 	}
 }
 
-func hasFlags() bool {
-	for _, arg := range os.Args[1:] {
+func hasFlags(args []string) bool {
+	for _, arg := range args {
+		// Also covers -at.
 		if strings.HasPrefix(arg, "-a") {
 			return true
 		}
@@ -72,6 +148,22 @@ func hasFlags() bool {
 		if strings.HasPrefix(arg, "-st") {
 			return true
 		}
+		if strings.HasPrefix(arg, "-k") {
+			return true
+		}
+		if strings.HasPrefix(arg, "-id") {
+			return true
+		}
+		if strings.HasPrefix(arg, "-node") {
+			return true
+		}
+		if strings.HasPrefix(arg, "-tp") {
+			return true
+		}
+		// Covers -cs, -ct and -cnt.
+		if strings.HasPrefix(arg, "-c") {
+			return true
+		}
 	}
 	return false
 }
@@ -95,15 +187,45 @@ func (c *Config) loadFromEnv() {
 	if st, exists := os.LookupEnv("STORAGE_TYPE"); exists {
 		c.StorageType = st
 	}
+	if secretKey, exists := os.LookupEnv("SECRET_KEY"); exists {
+		c.SecretKey = secretKey
+	}
+	if strategy, exists := os.LookupEnv("ID_STRATEGY"); exists {
+		c.IDStrategy = strategy
+	}
+	if length, exists := os.LookupEnv("ID_LENGTH"); exists {
+		c.IDLength = mustAtoi("ID_LENGTH", length)
+	}
+	if alphabet, exists := os.LookupEnv("ID_ALPHABET"); exists {
+		c.IDAlphabet = alphabet
+	}
+	if salt, exists := os.LookupEnv("ID_SALT"); exists {
+		c.IDSalt = salt
+	}
+	if nodeID, exists := os.LookupEnv("NODE_ID"); exists {
+		c.NodeID = mustAtoi("NODE_ID", nodeID)
+	}
+	if adminToken, exists := os.LookupEnv("ADMIN_TOKEN"); exists {
+		c.AdminToken = adminToken
+	}
+	if proxies, exists := os.LookupEnv("TRUSTED_PROXIES"); exists {
+		c.TrustedProxies = proxies
+	}
+	if size, exists := os.LookupEnv("CACHE_SIZE"); exists {
+		c.CacheSize = mustAtoi("CACHE_SIZE", size)
+	}
+	if ttl, exists := os.LookupEnv("CACHE_TTL"); exists {
+		c.CacheTTL = mustParseDuration("CACHE_TTL", ttl)
+	}
+	if ttl, exists := os.LookupEnv("CACHE_NEGATIVE_TTL"); exists {
+		c.CacheNegativeTTL = mustParseDuration("CACHE_NEGATIVE_TTL", ttl)
+	}
 }
 
 func (c *Config) setDefaults() {
 	if c.ServerAddress == "" {
 		c.ServerAddress = ":8080"
 	}
-	if c.BaseURL == "" {
-		c.BaseURL = "http://localhost"
-	}
 	if c.FileStoragePath == "" {
 		c.FileStoragePath = "/tmp/short-url-db.json"
 	}
@@ -120,6 +242,26 @@ func (c *Config) setDefaults() {
 	if c.StorageType == "" {
 		c.StorageType = "sqlite"
 	}
+	if c.SecretKey == "" {
+		// A fixed default would let anyone sign cookies for any user.
+		c.SecretKey = rand.Text()
+		log.Warn().Msg("SECRET_KEY is not set: using a random key, so user cookies and click IP hashes " +
+			"do not survive a restart and are not shared with other instances")
+	}
+	if c.IDStrategy == "" {
+		c.IDStrategy = "random"
+	}
+	if c.IDLength == 0 {
+		c.IDLength = 8
+	}
+	if c.IDAlphabet == "" {
+		c.IDAlphabet = DefaultIDAlphabet
+	}
+	if c.IDSalt == "" {
+		// Every node sharing the secret key then derives the same IDs.
+		// With a random secret key, hash IDs change on every restart.
+		c.IDSalt = c.SecretKey
+	}
 }
 
 func (c *Config) validate() {
@@ -133,4 +275,82 @@ func (c *Config) validate() {
 	if !validStorageTypes[c.StorageType] {
 		panic(fmt.Sprintf("invalid storage type: %s. Valid options are: memory, file, sqlite, postgres", c.StorageType))
 	}
+
+	validIDStrategies := map[string]bool{
+		"random":    true,
+		"counter":   true,
+		"hash":      true,
+		"snowflake": true,
+	}
+
+	if !validIDStrategies[c.IDStrategy] {
+		panic(fmt.Sprintf("invalid ID strategy: %s. Valid options are: random, counter, hash, snowflake", c.IDStrategy))
+	}
+	if c.IDLength < 4 || c.IDLength > 32 {
+		panic(fmt.Sprintf("invalid ID length: %d. It must be between 4 and 32", c.IDLength))
+	}
+	if !uniqueRunes(c.IDAlphabet) || len([]rune(c.IDAlphabet)) < 2 {
+		panic(fmt.Sprintf("invalid ID alphabet: %q. It must have at least 2 distinct characters", c.IDAlphabet))
+	}
+	if c.NodeID < 0 || c.NodeID > 1023 {
+		panic(fmt.Sprintf("invalid node ID: %d. It must be between 0 and 1023", c.NodeID))
+	}
+	if c.CacheSize < 0 {
+		panic(fmt.Sprintf("invalid cache size: %d. It must not be negative", c.CacheSize))
+	}
+	if c.CacheSize > 0 && c.CacheTTL <= 0 {
+		panic(fmt.Sprintf("invalid cache TTL: %s. It must be positive", c.CacheTTL))
+	}
+	if c.CacheNegativeTTL < 0 {
+		panic(fmt.Sprintf("invalid cache negative TTL: %s. It must not be negative", c.CacheNegativeTTL))
+	}
+	if c.BaseURL != "" && !validBaseURL(c.BaseURL) {
+		panic(fmt.Sprintf("invalid base URL: %q. It must be an absolute http or https URL without query", c.BaseURL))
+	}
+}
+
+// TrustedProxyList returns the configured trusted proxies.
+func (c *Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func validBaseURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil &&
+		(u.Scheme == "http" || u.Scheme == "https") &&
+		u.Host != "" &&
+		u.RawQuery == "" && !u.ForceQuery && u.Fragment == ""
+}
+
+func mustAtoi(name, value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s: %q is not a number", name, value))
+	}
+	return n
+}
+
+func mustParseDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s: %q is not a duration such as 30s or 5m", name, value))
+	}
+	return d
+}
+
+func uniqueRunes(s string) bool {
+	seen := make(map[rune]bool)
+	for _, r := range s {
+		if seen[r] {
+			return false
+		}
+		seen[r] = true
+	}
+	return true
 }
@@ -47,6 +47,84 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "400": {
+                        "description": "When the URL is invalid; gives the reason",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "When the URL is already shortened; returns the existing short URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/export": {
+            "get": {
+                "description": "Streams every stored link, deleted ones included, in short ID order. Requires the admin bearer token. If the export fails midway the connection is closed before the end of the response, so clients see a transfer error rather than a truncated file.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Export all links",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Output format: csv or ndjson (default ndjson)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003cadmin token\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One record per line",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ExportRecordDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "When the format is unknown",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "When the admin token is missing or wrong",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "When no admin token is configured",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
@@ -66,7 +144,7 @@ const docTemplate = `{
                 "summary": "Shorten a URL",
                 "parameters": [
                     {
-                        "description": "Original URL to be shortened",
+                        "description": "Original URL to be shortened, an optional custom alias and an optional expiry",
                         "name": "request",
                         "in": "body",
                         "required": true,
@@ -82,6 +160,21 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ShortenResponseDTO"
                         }
                     },
+                    "400": {
+                        "description": "When the request body, the URL, the alias or the expiry is invalid",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "When the URL is already shortened or the alias is taken; returns the existing short URL",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ShortenResponseDTO"
+                        }
+                    },
                     "500": {
                         "description": "When internal server error occurs",
                         "schema": {
@@ -96,7 +189,7 @@ const docTemplate = `{
         },
         "/api/shorten/batch": {
             "post": {
-                "description": "Accepts a batch of URLs and returns their shortened versions",
+                "description": "Accepts a batch of URLs and returns their shortened versions. The batch is stored all-or-nothing: on any error no URL of it is saved.",
                 "consumes": [
                     "application/json"
                 ],
@@ -109,7 +202,7 @@ const docTemplate = `{
                 "summary": "Shorten multiple URLs in a single request",
                 "parameters": [
                     {
-                        "description": "Array of URLs to shorten",
+                        "description": "Array of URLs to shorten, each with an optional custom alias and expiry",
                         "name": "request",
                         "in": "body",
                         "required": true,
@@ -131,6 +224,258 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "400": {
+                        "description": "When request body is invalid or empty, or a URL, an alias or an expiry is invalid",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "When an alias is taken; returns the existing link",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "When internal server error occurs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/shorten/import": {
+            "post": {
+                "description": "Reads newline-delimited JSON records, each with a correlation_id, an original_url and an optional alias, and stores them in chunks while the body is still uploading. The response streams one NDJSON result per record, in order, with its line number and either its short_url or the error that rejected it. A failing record does not fail the others; blank lines are skipped.",
+                "consumes": [
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "API"
+                ],
+                "summary": "Bulk import URLs",
+                "parameters": [
+                    {
+                        "description": "One record per line",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One result per record line",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImportResultDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/urls/{id}/stats": {
+            "get": {
+                "description": "Returns the total clicks, unique visitors (by hashed IP address), a time series with a point per bucket and the top referrers and user agents of the clicks in [from, to). Only the owner of the link can read them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API"
+                ],
+                "summary": "Get the click statistics of one of the caller's links",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bucket of the time series: hour or day (default day)",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 start of the range (default 48 hours or 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 end of the range, excluded (default now)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Length of the top lists (default 10, max 100)",
+                        "name": "top",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The link's statistics",
+                        "schema": {
+                            "$ref": "#/definitions/dto.LinkStatsDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "When the query is invalid",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "When the request has no valid user cookie",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "When the link belongs to another user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "When the link does not exist",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "When internal server error occurs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/urls": {
+            "get": {
+                "description": "Returns the caller's short URLs ordered by short ID. Use the X-Next-Cursor response header as the cursor query parameter to fetch the next page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API"
+                ],
+                "summary": "List URLs shortened by the current user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The caller's URLs",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.UserURLResponseDTO"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "The caller has no URLs"
+                    },
+                    "401": {
+                        "description": "When the request has no valid user cookie",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "When internal server error occurs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Accepts a batch of short URL IDs and queues those owned by the caller for deletion",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API"
+                ],
+                "summary": "Delete multiple URLs in a single request",
+                "parameters": [
+                    {
+                        "description": "Array of short URL IDs to delete",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
                     "400": {
                         "description": "When request body is invalid or empty",
                         "schema": {
@@ -140,6 +485,15 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "401": {
+                        "description": "When the request has no valid user cookie",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "When internal server error occurs",
                         "schema": {
@@ -221,6 +575,18 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "410": {
+                        "description": "Gone if the short URL has been deleted or has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "When the storage fails",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -230,11 +596,20 @@ const docTemplate = `{
         "dto.BatchRequestDTO": {
             "type": "object",
             "properties": {
+                "alias": {
+                    "type": "string"
+                },
                 "correlation_id": {
                     "type": "string"
                 },
+                "expires_at": {
+                    "type": "string"
+                },
                 "original_url": {
                     "type": "string"
+                },
+                "ttl_seconds": {
+                    "type": "integer"
                 }
             }
         },
@@ -249,9 +624,110 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.ExportRecordDTO": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "is_deleted": {
+                    "type": "boolean"
+                },
+                "original_url": {
+                    "type": "string"
+                },
+                "short_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ImportResultDTO": {
+            "type": "object",
+            "properties": {
+                "correlation_id": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "short_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.LinkStatsDTO": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "$ref": "#/definitions/dto.StatsBucket"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SeriesPoint"
+                    }
+                },
+                "short_id": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "top_referrers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ValueCount"
+                    }
+                },
+                "top_user_agents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ValueCount"
+                    }
+                },
+                "total_clicks": {
+                    "type": "integer"
+                },
+                "unique_visitors": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.SeriesPoint": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
         "dto.ShortenRequestDTO": {
             "type": "object",
             "properties": {
+                "alias": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "ttl_seconds": {
+                    "type": "integer"
+                },
                 "url": {
                     "type": "string"
                 }
@@ -264,6 +740,39 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "dto.StatsBucket": {
+            "type": "string",
+            "enum": [
+                "hour",
+                "day"
+            ],
+            "x-enum-varnames": [
+                "StatsBucketHour",
+                "StatsBucketDay"
+            ]
+        },
+        "dto.UserURLResponseDTO": {
+            "type": "object",
+            "properties": {
+                "original_url": {
+                    "type": "string"
+                },
+                "short_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ValueCount": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// exportFlushRecords is the number of records buffered between flushes.
+	exportFlushRecords = 1000
+	// exportIdleTimeout replaces the server write timeout while an export
+	// streams. It is renewed on every flush.
+	exportIdleTimeout = 30 * time.Second
+)
+
+var exportCSVHeader = []string{"short_id", "original_url", "user_id", "created_at", "expires_at", "is_deleted"}
+
+// HandleAPIExport Export every stored link
+// @Summary Export all links
+// @Description Streams every stored link, deleted ones included, in short ID order. Requires the admin bearer token. If the export fails midway the connection is closed before the end of the response, so clients see a transfer error rather than a truncated file.
+// @Tags Admin
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Output format: csv or ndjson (default ndjson)"
+// @Param Authorization header string true "Bearer <admin token>"
+// @Success 200 {object} dto.ExportRecordDTO "One record per line"
+// @Failure 400 {object} map[string]string "When the format is unknown"
+// @Failure 401 {object} map[string]string "When the admin token is missing or wrong"
+// @Failure 403 {object} map[string]string "When no admin token is configured"
+// @Router /api/admin/export [get]
+func (c *FiberURLController) HandleAPIExport(ctx *fiber.Ctx) error {
+	format := ctx.Query("format", "ndjson")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson",
+		})
+	}
+
+	// The fiber context is released before the response is streamed.
+	var (
+		userCtx = ctx.UserContext()
+		conn    = ctx.Context().Conn()
+	)
+
+	ctx.Status(fiber.StatusOK)
+	// Attachment guesses a content type from the extension: set ours after.
+	ctx.Attachment("links." + format)
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := c.streamExport(userCtx, format, w, conn); err != nil {
+			log.Error().Err(err).Msg("Error at export")
+			// Without the final chunk the client cannot take the partial
+			// export for a complete one.
+			conn.Close()
+		}
+	})
+	return nil
+}
+
+func (c *FiberURLController) streamExport(ctx context.Context, format string, w *bufio.Writer, conn net.Conn) error {
+	renewDeadline := func() {
+		conn.SetWriteDeadline(time.Now().Add(exportIdleTimeout))
+	}
+	renewDeadline()
+
+	var (
+		write func(record dto.ExportRecordDTO) error
+		flush func() error
+	)
+	if format == "csv" {
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		write = func(record dto.ExportRecordDTO) error {
+			return csvWriter.Write([]string{
+				record.ShortID, record.OriginalURL, record.UserID, record.CreatedAt, record.ExpiresAt,
+				strconv.FormatBool(record.IsDeleted),
+			})
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+	} else {
+		encoder := json.NewEncoder(w)
+		write = func(record dto.ExportRecordDTO) error {
+			return encoder.Encode(record)
+		}
+		flush = w.Flush
+	}
+
+	var written int
+	err := c.service.ExportURLs(ctx, func(record dto.URLRecord) error {
+		exported := dto.ExportRecordDTO{
+			ShortID:     record.ShortURL,
+			OriginalURL: record.OriginalURL,
+			UserID:      record.UserID,
+			IsDeleted:   record.IsDeleted,
+		}
+		if !record.CreatedAt.IsZero() {
+			exported.CreatedAt = record.CreatedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if !record.ExpiresAt.IsZero() {
+			exported.ExpiresAt = record.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		}
+		if err := write(exported); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushRecords != 0 {
+			return nil
+		}
+		renewDeadline()
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHandleAPIExport(t *testing.T) {
+	records := []dto.URLRecord{
+		{
+			ShortURL:    "abc",
+			OriginalURL: "https://example.com/?q=a,b",
+			UserID:      "u1",
+			CreatedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			ExpiresAt:   time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{ShortURL: "def", OriginalURL: "https://example.org", IsDeleted: true},
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		exportErr           error
+		expectExport        bool
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "CSV",
+			query:               "?format=csv",
+			expectExport:        true,
+			expectedStatus:      fiber.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody: "short_id,original_url,user_id,created_at,expires_at,is_deleted\n" +
+				"abc,\"https://example.com/?q=a,b\",u1,2026-01-02T03:04:05Z,2027-01-02T03:04:05Z,false\n" +
+				"def,https://example.org,,,,true\n",
+		},
+		{
+			name:                "NDJSON by default",
+			expectExport:        true,
+			expectedStatus:      fiber.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"short_id":"abc","original_url":"https://example.com/?q=a,b","user_id":"u1","created_at":"2026-01-02T03:04:05Z","expires_at":"2027-01-02T03:04:05Z","is_deleted":false}` + "\n" +
+				`{"short_id":"def","original_url":"https://example.org","user_id":"","created_at":"","expires_at":"","is_deleted":true}` + "\n",
+		},
+		{
+			name:                "Export fails midway",
+			query:               "?format=ndjson",
+			exportErr:           errors.New("db error"),
+			expectExport:        true,
+			expectedStatus:      fiber.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        `{"short_id":"abc","original_url":"https://example.com/?q=a,b","user_id":"u1","created_at":"2026-01-02T03:04:05Z","expires_at":"2027-01-02T03:04:05Z","is_deleted":false}` + "\n",
+		},
+		{
+			name:           "Unknown format",
+			query:          "?format=xml",
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `{"error":"format must be csv or ndjson"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, mockService, ctrl := setupTestController(t)
+			defer ctrl.Finish()
+
+			if tt.expectExport {
+				mockService.EXPECT().
+					ExportURLs(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, yield func(dto.URLRecord) error) error {
+						for _, record := range records {
+							if err := yield(record); err != nil {
+								return err
+							}
+							if tt.exportErr != nil {
+								return tt.exportErr
+							}
+						}
+						return nil
+					})
+			}
+
+			app := fiber.New()
+			app.Get("/api/admin/export", controller.HandleAPIExport)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/api/admin/export"+tt.query, nil))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, resp.Header.Get("Content-Type"))
+				assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+			}
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// importChunkSize is the number of lines stored at once.
+	importChunkSize = 500
+	// maxImportLineSize bounds a single NDJSON line.
+	maxImportLineSize = 64 * 1024
+	// importIdleTimeout replaces the server read and write timeouts while an
+	// import streams. It is renewed for every chunk.
+	importIdleTimeout = 30 * time.Second
+)
+
+// importLine is a parsed line of an import waiting for its chunk to be stored.
+type importLine struct {
+	number  int
+	request dto.BatchRequestDTO
+	err     error
+}
+
+// HandleAPIImport Import URLs from an NDJSON stream
+// @Summary Bulk import URLs
+// @Description Reads newline-delimited JSON records, each with a correlation_id, an original_url and an optional alias, and stores them in chunks while the body is still uploading. The response streams one NDJSON result per record, in order, with its line number and either its short_url or the error that rejected it. A failing record does not fail the others; blank lines are skipped.
+// @Tags API
+// @Accept application/x-ndjson
+// @Produce application/x-ndjson
+// @Param request body dto.BatchRequestDTO true "One record per line"
+// @Success 200 {object} dto.ImportResultDTO "One result per record line"
+// @Router /api/shorten/import [post]
+func (c *FiberURLController) HandleAPIImport(ctx *fiber.Ctx) error {
+	body := ctx.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Body())
+	}
+
+	// The fiber context is released once the handler returns, before the
+	// response is streamed: take everything needed from it now.
+	var (
+		userCtx = ctx.UserContext()
+		userID  = middleware.GetUserID(ctx)
+		baseURL = c.links.Base(ctx)
+		conn    = ctx.Context().Conn()
+	)
+
+	ctx.Status(fiber.StatusOK)
+	ctx.Set(fiber.HeaderContentType, "application/x-ndjson")
+	// An import that stops early leaves the rest of the body unread, so the
+	// connection cannot serve another request.
+	ctx.Context().SetConnectionClose()
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		c.streamImport(userCtx, userID, baseURL, body, w, conn)
+	})
+	return nil
+}
+
+// streamImport reads the import from body and writes its results to w, one
+// chunk at a time, until the body ends or an error stops the import.
+func (c *FiberURLController) streamImport(
+	ctx context.Context, userID, baseURL string, body io.Reader, w *bufio.Writer, conn net.Conn,
+) {
+	renewDeadlines := func() {
+		deadline := time.Now().Add(importIdleTimeout)
+		conn.SetReadDeadline(deadline)
+		conn.SetWriteDeadline(deadline)
+	}
+	renewDeadlines()
+
+	encoder := json.NewEncoder(w)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+
+	var (
+		number  int
+		pending = make([]importLine, 0, importChunkSize)
+	)
+	for scanner.Scan() {
+		number++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		parsed := importLine{number: number}
+		if err := json.Unmarshal(line, &parsed.request); err != nil {
+			parsed.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		pending = append(pending, parsed)
+		if len(pending) < importChunkSize {
+			continue
+		}
+
+		if !c.storeImportChunk(ctx, userID, baseURL, pending, encoder, w) {
+			return
+		}
+		pending = pending[:0]
+		renewDeadlines()
+	}
+
+	if !c.storeImportChunk(ctx, userID, baseURL, pending, encoder, w) {
+		return
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			encoder.Encode(dto.ImportResultDTO{
+				Line:  number + 1,
+				Error: fmt.Sprintf("line is longer than %d bytes; import stopped", maxImportLineSize),
+			})
+		} else {
+			log.Error().Err(err).Int("line", number).Msg("Error reading import")
+		}
+		w.Flush()
+	}
+}
+
+// storeImportChunk stores the pending lines and writes their results. It
+// reports whether the import can go on.
+func (c *FiberURLController) storeImportChunk(
+	ctx context.Context, userID, baseURL string, pending []importLine, encoder *json.Encoder, w *bufio.Writer,
+) bool {
+	if len(pending) == 0 {
+		return true
+	}
+
+	requests := make([]dto.BatchRequestDTO, 0, len(pending))
+	for _, line := range pending {
+		if line.err == nil {
+			requests = append(requests, line.request)
+		}
+	}
+
+	var (
+		results []dto.ImportResult
+		err     error
+	)
+	if len(requests) > 0 {
+		results, err = c.service.ImportURLs(ctx, userID, requests)
+	}
+	if err != nil {
+		log.Error().Err(err).Int("line", pending[0].number).Msg("Error at import")
+	}
+
+	next := 0
+	for _, line := range pending {
+		result := dto.ImportResultDTO{
+			Line:          line.number,
+			CorrelationID: line.request.CorrelationID,
+		}
+		switch {
+		case line.err != nil:
+			result.Error = line.err.Error()
+		case err != nil:
+			result.Error = err.Error()
+		case results[next].Err != nil:
+			result.Error = results[next].Err.Error()
+		default:
+			result.ShortURL = JoinLink(baseURL, results[next].ShortID)
+		}
+		if line.err == nil {
+			next++
+		}
+		encoder.Encode(result)
+	}
+
+	if flushErr := w.Flush(); flushErr != nil {
+		log.Warn().Err(flushErr).Msg("Import client went away")
+		return false
+	}
+	// A chunk that could not be stored at all stops the import: the results
+	// end at its last line.
+	return err == nil
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func setupImportApp(controller *FiberURLController) *fiber.App {
+	// A small BodyLimit makes the test bodies stream as in production.
+	app := fiber.New(fiber.Config{BodyLimit: 1024, StreamRequestBody: true})
+	app.Post("/api/shorten/import", controller.HandleAPIImport)
+	return app
+}
+
+func postImport(t *testing.T, app *fiber.App, body string) []dto.ImportResultDTO {
+	req := httptest.NewRequest("POST", "/api/shorten/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	var results []dto.ImportResultDTO
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result dto.ImportResultDTO
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results = append(results, result)
+	}
+	require.NoError(t, scanner.Err())
+	return results
+}
+
+// importBody returns n record lines whose correlation IDs are their line
+// numbers.
+func importBody(n int) []string {
+	lines := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		lines = append(lines, fmt.Sprintf(`{"correlation_id":"%d","original_url":"https://example.com/%d"}`, i, i))
+	}
+	return lines
+}
+
+func TestHandleAPIImport(t *testing.T) {
+	controller, mockService, ctrl := setupTestController(t)
+	defer ctrl.Finish()
+
+	lines := importBody(importChunkSize + 10)
+	lines[1] = "{not json"
+	lines[2] = ""
+	lines[3] = `{"correlation_id":"4"}`
+
+	var calls int
+	mockService.EXPECT().
+		ImportURLs(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, requests []dto.BatchRequestDTO) ([]dto.ImportResult, error) {
+			calls++
+			results := make([]dto.ImportResult, len(requests))
+			for i, request := range requests {
+				if request.OriginalURL == "" {
+					results[i].Err = services.ErrEmptyURL
+					continue
+				}
+				results[i].ShortID = "id" + request.CorrelationID
+			}
+			return results, nil
+		}).
+		Times(2)
+
+	results := postImport(t, setupImportApp(controller), strings.Join(lines, "\n")+"\n")
+
+	// The blank line gets no result; every other line gets one, in order.
+	require.Len(t, results, len(lines)-1)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, dto.ImportResultDTO{Line: 1, CorrelationID: "1", ShortURL: "http://example.com/id1"}, results[0])
+	assert.Equal(t, 2, results[1].Line)
+	assert.Contains(t, results[1].Error, "invalid JSON")
+	assert.Equal(t, dto.ImportResultDTO{Line: 4, CorrelationID: "4", Error: services.ErrEmptyURL.Error()}, results[2])
+	for i := 3; i < len(results); i++ {
+		assert.Equal(t, i+2, results[i].Line)
+		assert.Equal(t, fmt.Sprintf("http://example.com/id%d", i+2), results[i].ShortURL)
+	}
+}
+
+func TestHandleAPIImportStopsOnServiceError(t *testing.T) {
+	controller, mockService, ctrl := setupTestController(t)
+	defer ctrl.Finish()
+
+	mockService.EXPECT().
+		ImportURLs(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("db error")).
+		Times(1)
+
+	results := postImport(t, setupImportApp(controller), strings.Join(importBody(importChunkSize*2), "\n"))
+
+	require.Len(t, results, importChunkSize)
+	for _, result := range results {
+		assert.Equal(t, "db error", result.Error)
+	}
+}
+
+func TestHandleAPIImportLineTooLong(t *testing.T) {
+	controller, mockService, ctrl := setupTestController(t)
+	defer ctrl.Finish()
+
+	mockService.EXPECT().
+		ImportURLs(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]dto.ImportResult{{ShortID: "id1"}}, nil).
+		Times(1)
+
+	body := importBody(1)[0] + "\n" + `{"original_url":"https://example.com/` + strings.Repeat("a", maxImportLineSize) + `"}`
+	results := postImport(t, setupImportApp(controller), body)
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "http://example.com/id1", results[0].ShortURL)
+	assert.Equal(t, 2, results[1].Line)
+	assert.Contains(t, results[1].Error, "import stopped")
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/middleware"
+	"github.com/VladimirAzanza/url-shortener/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// HandleAPIGetURLStats Get the click statistics of a link
+// @Summary Get the click statistics of one of the caller's links
+// @Description Returns the total clicks, unique visitors (by hashed IP address), a time series with a point per bucket and the top referrers and user agents of the clicks in [from, to). Only the owner of the link can read them.
+// @Tags API
+// @Produce json
+// @Param id path string true "Short URL ID"
+// @Param bucket query string false "Bucket of the time series: hour or day (default day)"
+// @Param from query string false "RFC 3339 start of the range (default 48 hours or 30 days before to)"
+// @Param to query string false "RFC 3339 end of the range, excluded (default now)"
+// @Param top query int false "Length of the top lists (default 10, max 100)"
+// @Success 200 {object} dto.LinkStatsDTO "The link's statistics"
+// @Failure 400 {object} map[string]string "When the query is invalid"
+// @Failure 401 {object} map[string]string "When the request has no valid user cookie"
+// @Failure 403 {object} map[string]string "When the link belongs to another user"
+// @Failure 404 {object} map[string]string "When the link does not exist"
+// @Failure 500 {object} map[string]string "When internal server error occurs"
+// @Router /api/urls/{id}/stats [get]
+func (c *FiberURLController) HandleAPIGetURLStats(ctx *fiber.Ctx) error {
+	query := dto.StatsQuery{
+		Bucket: dto.StatsBucket(ctx.Query("bucket")),
+		Top:    ctx.QueryInt("top"),
+	}
+	bounds := []struct {
+		name  string
+		value *time.Time
+	}{{"from", &query.From}, {"to", &query.To}}
+	for _, bound := range bounds {
+		raw := ctx.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": bound.name + " must be an RFC 3339 time",
+			})
+		}
+		*bound.value = parsed
+	}
+
+	stats, err := c.service.GetURLStats(ctx.UserContext(), middleware.GetUserID(ctx), ctx.Params("id"), query)
+	switch {
+	case errors.Is(err, services.ErrInvalidStatsQuery):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrLinkNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "URL not found",
+		})
+	case errors.Is(err, services.ErrNotLinkOwner):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "URL belongs to another user",
+		})
+	case err != nil:
+		log.Error().Err(err).Msg("Error at getting url stats")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(stats)
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHandleAPIGetURLStats(t *testing.T) {
+	from := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	stats := dto.LinkStatsDTO{
+		ShortID: "abc",
+		Bucket:  dto.StatsBucketHour,
+		From:    from,
+		To:      to,
+		ClickStats: dto.ClickStats{
+			TotalClicks:    2,
+			UniqueVisitors: 1,
+			Series:         []dto.SeriesPoint{{Start: from, Clicks: 2}},
+			TopReferrers:   []dto.ValueCount{{Value: "https://r1.example/", Clicks: 2}},
+			TopUserAgents:  []dto.ValueCount{},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedQuery  dto.StatsQuery
+		expectService  bool
+		serviceError   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			query:          "?bucket=hour&from=2026-01-02T10:00:00Z&to=2026-01-02T11:00:00Z&top=5",
+			expectedQuery:  dto.StatsQuery{Bucket: dto.StatsBucketHour, From: from, To: to, Top: 5},
+			expectService:  true,
+			expectedStatus: fiber.StatusOK,
+			expectedBody: `{"short_id":"abc","bucket":"hour","from":"2026-01-02T10:00:00Z","to":"2026-01-02T11:00:00Z",` +
+				`"total_clicks":2,"unique_visitors":1,"series":[{"start":"2026-01-02T10:00:00Z","clicks":2}],` +
+				`"top_referrers":[{"value":"https://r1.example/","clicks":2}],"top_user_agents":[]}`,
+		},
+		{
+			name:           "Invalid time",
+			query:          "?from=yesterday",
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `{"error":"from must be an RFC 3339 time"}`,
+		},
+		{
+			name:           "Invalid query",
+			query:          "?bucket=week",
+			expectedQuery:  dto.StatsQuery{Bucket: "week"},
+			expectService:  true,
+			serviceError:   fmt.Errorf("%w: bucket must be hour or day", services.ErrInvalidStatsQuery),
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `{"error":"invalid stats query: bucket must be hour or day"}`,
+		},
+		{
+			name:           "Not found",
+			expectService:  true,
+			serviceError:   services.ErrLinkNotFound,
+			expectedStatus: fiber.StatusNotFound,
+			expectedBody:   `{"error":"URL not found"}`,
+		},
+		{
+			name:           "Not the owner",
+			expectService:  true,
+			serviceError:   services.ErrNotLinkOwner,
+			expectedStatus: fiber.StatusForbidden,
+			expectedBody:   `{"error":"URL belongs to another user"}`,
+		},
+		{
+			name:           "Service error",
+			expectService:  true,
+			serviceError:   errors.New("db error"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   `{"error":"db error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, mockService, ctrl := setupTestController(t)
+			defer ctrl.Finish()
+
+			if tt.expectService {
+				result := stats
+				if tt.serviceError != nil {
+					result = dto.LinkStatsDTO{}
+				}
+				mockService.EXPECT().
+					GetURLStats(gomock.Any(), gomock.Any(), "abc", tt.expectedQuery).
+					Return(result, tt.serviceError).
+					Times(1)
+			}
+
+			app := fiber.New()
+			app.Get("/api/urls/:id/stats", controller.HandleAPIGetURLStats)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/api/urls/abc/stats"+tt.query, nil))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+}
@@ -3,23 +3,27 @@ package controller
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/VladimirAzanza/url-shortener/internal/constants"
 	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/middleware"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
 	"github.com/VladimirAzanza/url-shortener/internal/services"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/rs/zerolog/log"
 )
 
 type FiberURLController struct {
 	service services.IURLService
+	links   *LinkBuilder
 }
 
-func NewFiberURLController(service services.IURLService) *FiberURLController {
+func NewFiberURLController(service services.IURLService, links *LinkBuilder) *FiberURLController {
 	return &FiberURLController{
 		service: service,
+		links:   links,
 	}
 }
 
@@ -31,11 +35,21 @@ func NewFiberURLController(service services.IURLService) *FiberURLController {
 // @Produce plain
 // @Param originalUrl body string true "Original URL to be shortened"
 // @Success 201 {string} string "Returns the shortened URL"
+// @Failure 400 {object} map[string]string "When the URL is invalid; gives the reason"
+// @Failure 409 {string} string "When the URL is already shortened; returns the existing short URL"
 // @Router / [post]
 func (c *FiberURLController) HandlePost(ctx *fiber.Ctx) error {
-	baseURL := ctx.BaseURL()
 	originalURL := ctx.BodyRaw()
-	shortID, err := c.service.ShortenURL(ctx.UserContext(), string(originalURL))
+	shortID, err := c.service.ShortenURL(ctx.UserContext(), middleware.GetUserID(ctx), string(originalURL))
+	var conflict *repo.ErrURLConflict
+	if errors.As(err, &conflict) {
+		return ctx.Status(fiber.StatusConflict).SendString(c.links.Link(ctx, conflict.ShortID))
+	}
+	if errors.Is(err, services.ErrInvalidURL) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Error at shorten api url")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -43,7 +57,7 @@ func (c *FiberURLController) HandlePost(ctx *fiber.Ctx) error {
 		})
 	}
 
-	return ctx.Status(fiber.StatusCreated).SendString(baseURL + "/" + shortID)
+	return ctx.Status(fiber.StatusCreated).SendString(c.links.Link(ctx, shortID))
 }
 
 // @Summary Verifies the connection to the DB
@@ -76,8 +90,10 @@ func (c *FiberURLController) GetDBPing(ctx *fiber.Ctx) error {
 // @Tags API
 // @Accept plain
 // @Produce plain
-// @Param request body dto.ShortenRequestDTO true "Original URL to be shortened"
+// @Param request body dto.ShortenRequestDTO true "Original URL to be shortened, an optional custom alias and an optional expiry"
 // @Success 201 {object} dto.ShortenResponseDTO "Returns the shortened URL"
+// @Failure 400 {object} map[string]string "When the request body, the URL, the alias or the expiry is invalid"
+// @Failure 409 {object} dto.ShortenResponseDTO "When the URL is already shortened or the alias is taken; returns the existing short URL"
 // @Failure 500 {object} map[string]string "When internal server error occurs"
 // @Router /api/shorten [post]
 func (c *FiberURLController) HandleAPIPost(ctx *fiber.Ctx) error {
@@ -89,17 +105,31 @@ func (c *FiberURLController) HandleAPIPost(ctx *fiber.Ctx) error {
 		})
 	}
 
-	shortID, err := c.service.ShortenAPIURL(ctx.UserContext(), &shortenRequestDTO)
-	if err != nil {
+	shortID, err := c.service.ShortenAPIURL(ctx.UserContext(), middleware.GetUserID(ctx), &shortenRequestDTO)
+	var conflict *repo.ErrURLConflict
+	switch {
+	case errors.As(err, &conflict):
+		return ctx.Status(fiber.StatusConflict).JSON(dto.ShortenResponseDTO{
+			Result: c.links.Link(ctx, conflict.ShortID),
+		})
+	case errors.Is(err, services.ErrInvalidAlias), errors.Is(err, services.ErrInvalidURL),
+		errors.Is(err, services.ErrInvalidExpiry):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, repo.ErrShortIDTaken) && shortenRequestDTO.Alias != "":
+		return ctx.Status(fiber.StatusConflict).JSON(dto.ShortenResponseDTO{
+			Result: c.links.Link(ctx, shortenRequestDTO.Alias),
+		})
+	case err != nil:
 		log.Error().Err(err).Msg("Error at shorten api url")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	fullURL := fmt.Sprintf("%s/%s", ctx.BaseURL(), shortID)
 	response := dto.ShortenResponseDTO{
-		Result: fullURL,
+		Result: c.links.Link(ctx, shortID),
 	}
 
 	log.Info().Msg("Successfully shortened the url, shortID" + response.Result)
@@ -114,7 +144,9 @@ func (c *FiberURLController) HandleAPIPost(ctx *fiber.Ctx) error {
 // @Param id path string true "Short URL ID"
 // @Success 307 "Redirects to original URL"
 // @Failure 404 {string} string "Not found if short ID doesn't exist"
+// @Failure 410 {string} string "Gone if the short URL has been deleted or has expired"
 // @Failure 408 {string} string "Request timeout"
+// @Failure 500 {string} string "When the storage fails"
 // @Router /{id} [get]
 func (c *FiberURLController) HandleGet(ctx *fiber.Ctx) error {
 	shortID := ctx.Params("id")
@@ -122,8 +154,14 @@ func (c *FiberURLController) HandleGet(ctx *fiber.Ctx) error {
 	reqCtx, cancel := context.WithTimeout(ctx.UserContext(), 1*time.Second)
 	defer cancel()
 
-	originalURL, exists := c.service.GetOriginalURL(reqCtx, shortID)
-	switch err := reqCtx.Err(); {
+	originalURL, err := c.service.GetOriginalURL(reqCtx, shortID)
+	switch {
+	case errors.Is(err, services.ErrLinkNotFound):
+		return ctx.Status(fiber.StatusNotFound).SendString("URL not found")
+	case errors.Is(err, repo.ErrURLDeleted):
+		return ctx.Status(fiber.StatusGone).SendString("URL has been deleted")
+	case errors.Is(err, repo.ErrURLExpired):
+		return ctx.Status(fiber.StatusGone).SendString("URL has expired")
 	case errors.Is(err, context.DeadlineExceeded):
 		log.Warn().Str("shortID", shortID).Msg("Request timeout exceeded (server-side)")
 		return ctx.Status(fiber.StatusRequestTimeout).SendString("Request timeout")
@@ -131,27 +169,31 @@ func (c *FiberURLController) HandleGet(ctx *fiber.Ctx) error {
 		log.Warn().Str("shortID", shortID).Msg("Request canceled by client")
 		return ctx.Status(499).SendString("Client closed connection")
 	case err != nil:
-		log.Error().Err(err).Msg("Unexpected Error")
+		log.Error().Err(err).Str("shortID", shortID).Msg("Error getting original URL")
 		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
 	}
 
-	if !exists {
-		return ctx.Status(fiber.StatusNotFound).SendString("URL not found")
-	}
-
+	// The click outlives the request, whose buffers fiber reuses.
+	c.service.RecordClick(
+		utils.CopyString(shortID),
+		utils.CopyString(ctx.Get(fiber.HeaderReferer)),
+		utils.CopyString(ctx.Get(fiber.HeaderUserAgent)),
+		utils.CopyString(ctx.IP()),
+	)
 	log.Info().Str("shortID", shortID).Str("originalURL", originalURL).Msg("Redirect to original URL")
 	return ctx.Redirect(originalURL, fiber.StatusTemporaryRedirect)
 }
 
 // HandleAPIPostBatch Shorten multiple URLs in batch
 // @Summary Shorten multiple URLs in a single request
-// @Description Accepts a batch of URLs and returns their shortened versions
+// @Description Accepts a batch of URLs and returns their shortened versions. The batch is stored all-or-nothing: on any error no URL of it is saved.
 // @Tags API
 // @Accept json
 // @Produce json
-// @Param request body []dto.BatchRequestDTO true "Array of URLs to shorten"
+// @Param request body []dto.BatchRequestDTO true "Array of URLs to shorten, each with an optional custom alias and expiry"
 // @Success 201 {array} dto.BatchResponseDTO "Returns an array of shortened URLs"
-// @Failure 400 {object} map[string]string "When request body is invalid or empty"
+// @Failure 400 {object} map[string]string "When request body is invalid or empty, or a URL, an alias or an expiry is invalid"
+// @Failure 409 {object} map[string]string "When an alias is taken; returns the existing link"
 // @Failure 500 {object} map[string]string "When internal server error occurs"
 // @Router /api/shorten/batch [post]
 func (c *FiberURLController) HandleAPIPostBatch(ctx *fiber.Ctx) error {
@@ -169,33 +211,106 @@ func (c *FiberURLController) HandleAPIPostBatch(ctx *fiber.Ctx) error {
 		})
 	}
 
-	responses := make([]dto.BatchResponseDTO, 0, len(batchRequestDTO))
-	for _, req := range batchRequestDTO {
-		shortID, err := c.service.BatchShortenURL(ctx.UserContext(), req)
-		if err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
+	shortIDs, err := c.service.BatchShortenURLs(ctx.UserContext(), middleware.GetUserID(ctx), batchRequestDTO)
+	if err != nil {
+		return c.batchError(ctx, batchRequestDTO, err)
+	}
 
+	responses := make([]dto.BatchResponseDTO, 0, len(batchRequestDTO))
+	for i, req := range batchRequestDTO {
 		responses = append(responses, dto.BatchResponseDTO{
 			CorrelationID: req.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", ctx.BaseURL(), shortID),
+			ShortURL:      c.links.Link(ctx, shortIDs[i]),
 		})
 	}
 	return ctx.Status(fiber.StatusCreated).JSON(responses)
 }
 
+// batchError answers a failed batch, pointing at the item that caused it
+// when known. Nothing of the batch has been stored.
+func (c *FiberURLController) batchError(ctx *fiber.Ctx, requests []dto.BatchRequestDTO, err error) error {
+	var (
+		itemErr *repo.BatchItemError
+		req     dto.BatchRequestDTO
+	)
+	if errors.As(err, &itemErr) && itemErr.Index < len(requests) {
+		req = requests[itemErr.Index]
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvalidAlias), errors.Is(err, services.ErrInvalidURL),
+		errors.Is(err, services.ErrInvalidExpiry):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          err.Error(),
+			"correlation_id": req.CorrelationID,
+		})
+	case errors.Is(err, repo.ErrShortIDTaken) && req.Alias != "":
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":          "alias is already taken",
+			"correlation_id": req.CorrelationID,
+			"short_url":      c.links.Link(ctx, req.Alias),
+		})
+	default:
+		log.Error().Err(err).Msg("Error at shorten batch")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+}
+
+// HandleAPIGetUserURLs List the caller's URLs
+// @Summary List URLs shortened by the current user
+// @Description Returns the caller's short URLs ordered by short ID. Use the X-Next-Cursor response header as the cursor query parameter to fetch the next page.
+// @Tags API
+// @Produce json
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Success 200 {array} dto.UserURLResponseDTO "The caller's URLs"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Success 204 "The caller has no URLs"
+// @Failure 401 {object} map[string]string "When the request has no valid user cookie"
+// @Failure 500 {object} map[string]string "When internal server error occurs"
+// @Router /api/user/urls [get]
+func (c *FiberURLController) HandleAPIGetUserURLs(ctx *fiber.Ctx) error {
+	records, nextCursor, err := c.service.GetUserURLs(
+		ctx.UserContext(), middleware.GetUserID(ctx), ctx.Query("cursor"), ctx.QueryInt("limit"),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Error at getting user urls")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if len(records) == 0 {
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+
+	responses := make([]dto.UserURLResponseDTO, 0, len(records))
+	for _, record := range records {
+		responses = append(responses, dto.UserURLResponseDTO{
+			ShortURL:    c.links.Link(ctx, record.ShortURL),
+			OriginalURL: record.OriginalURL,
+		})
+	}
+
+	if nextCursor != "" {
+		ctx.Set("X-Next-Cursor", nextCursor)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(responses)
+}
+
 // HandleAPIDeleteBatch Delete multiple URLs in batch
 // @Summary Delete multiple URLs in a single request
-// @Description Accepts a batch of URLs
+// @Description Accepts a batch of short URL IDs and queues those owned by the caller for deletion
 // @Tags API
 // @Accept json
-// @Param request body dto.DeleteURLsRequestDTO true "Array of URLs to delete"
-// @Success 202 {array}
+// @Param request body []string true "Array of short URL IDs to delete"
+// @Success 202 "Accepted"
 // @Failure 400 {object} map[string]string "When request body is invalid or empty"
+// @Failure 401 {object} map[string]string "When the request has no valid user cookie"
 // @Failure 500 {object} map[string]string "When internal server error occurs"
-// @Router /api/user/urls [post]
+// @Router /api/user/urls [delete]
 func (c *FiberURLController) HandleAPIDeleteBatch(ctx *fiber.Ctx) error {
 	var batchRequestDTO dto.DeleteURLsRequestDTO
 	if err := ctx.BodyParser(&batchRequestDTO.URLIDs); err != nil {
@@ -210,14 +325,9 @@ func (c *FiberURLController) HandleAPIDeleteBatch(ctx *fiber.Ctx) error {
 		})
 	}
 
-	var err error
-	if len(batchRequestDTO.URLIDs) > 1 {
-		err = c.service.ConcurrentBatchDelete(ctx.Context(), batchRequestDTO.URLIDs)
-	} else {
-		err = c.service.BatchDeleteURLs(ctx.Context(), batchRequestDTO.URLIDs)
-	}
-
+	err := c.service.DeleteURLs(ctx.UserContext(), middleware.GetUserID(ctx), batchRequestDTO.URLIDs)
 	if err != nil {
+		log.Error().Err(err).Msg("Error at queueing batch delete")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to process batch delete",
 		})
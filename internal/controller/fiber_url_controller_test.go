@@ -1,13 +1,19 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/VladimirAzanza/url-shortener/config"
 	"github.com/VladimirAzanza/url-shortener/internal/constants"
 	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/services"
 	"github.com/VladimirAzanza/url-shortener/mocks"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +23,7 @@ import (
 func setupTestController(t *testing.T) (*FiberURLController, *mocks.MockIURLService, *gomock.Controller) {
 	ctrl := gomock.NewController(t)
 	mockService := mocks.NewMockIURLService(ctrl)
-	controller := NewFiberURLController(mockService)
+	controller := NewFiberURLController(mockService, NewLinkBuilder(&config.Config{}))
 	return controller, mockService, ctrl
 }
 
@@ -83,42 +89,42 @@ func TestHandleAPIDeleteBatch(t *testing.T) {
 	tests := []struct {
 		name           string
 		requestBody    string
-		batchSize      int
+		expectService  bool
 		serviceError   error
 		expectedStatus int
 	}{
 		{
 			name:           "Single item",
 			requestBody:    `["abc123"]`,
-			batchSize:      1,
+			expectService:  true,
 			serviceError:   nil,
 			expectedStatus: fiber.StatusAccepted,
 		},
 		{
 			name:           "Multiple items",
 			requestBody:    `["abc123","def456"]`,
-			batchSize:      2,
+			expectService:  true,
 			serviceError:   nil,
 			expectedStatus: fiber.StatusAccepted,
 		},
 		{
 			name:           "Invalid JSON",
 			requestBody:    `invalid json`,
-			batchSize:      0,
+			expectService:  false,
 			serviceError:   nil,
 			expectedStatus: fiber.StatusBadRequest,
 		},
 		{
 			name:           "Empty batch",
 			requestBody:    `[]`,
-			batchSize:      0,
+			expectService:  false,
 			serviceError:   nil,
 			expectedStatus: fiber.StatusBadRequest,
 		},
 		{
 			name:           "Service error",
 			requestBody:    `["abc123"]`,
-			batchSize:      1,
+			expectService:  true,
 			serviceError:   errors.New("service error"),
 			expectedStatus: fiber.StatusInternalServerError,
 		},
@@ -130,21 +136,18 @@ func TestHandleAPIDeleteBatch(t *testing.T) {
 			defer ctrl.Finish()
 
 			app := fiber.New()
-			app.Post("/api/user/urls", controller.HandleAPIDeleteBatch)
+			app.Delete("/api/user/urls", controller.HandleAPIDeleteBatch)
 
-			if tt.batchSize == 1 {
+			if tt.expectService {
+				var ids []string
+				assert.NoError(t, json.Unmarshal([]byte(tt.requestBody), &ids))
 				mockService.EXPECT().
-					BatchDeleteURLs(gomock.Any(), []string{"abc123"}).
-					Return(tt.serviceError).
-					Times(1)
-			} else if tt.batchSize == 2 {
-				mockService.EXPECT().
-					ConcurrentBatchDelete(gomock.Any(), []string{"abc123", "def456"}).
+					DeleteURLs(gomock.Any(), gomock.Any(), ids).
 					Return(tt.serviceError).
 					Times(1)
 			}
 
-			req := httptest.NewRequest("POST", "/api/user/urls", strings.NewReader(tt.requestBody))
+			req := httptest.NewRequest("DELETE", "/api/user/urls", strings.NewReader(tt.requestBody))
 			req.Header.Set("Content-Type", "application/json")
 
 			resp, err := app.Test(req)
@@ -181,6 +184,22 @@ func TestHandlePost(t *testing.T) {
 			expectedStatus: fiber.StatusInternalServerError,
 			expectedBody:   `{"error":"service error"}`,
 		},
+		{
+			name:           "Already shortened",
+			body:           "https://example.com/existing",
+			serviceReturns: "",
+			serviceError:   &repo.ErrURLConflict{ShortID: "existing1"},
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   "http://example.com/existing1",
+		},
+		{
+			name:           "Invalid URL",
+			body:           "www.example2.com",
+			serviceReturns: "",
+			serviceError:   fmt.Errorf("%w: it must start with http:// or https://", services.ErrInvalidURL),
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `{"error":"invalid URL: it must start with http:// or https://"}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -193,7 +212,7 @@ func TestHandlePost(t *testing.T) {
 
 			if tt.body != "" {
 				mockService.EXPECT().
-					ShortenURL(gomock.Any(), tt.body).
+					ShortenURL(gomock.Any(), gomock.Any(), tt.body).
 					Return(tt.serviceReturns, tt.serviceError).
 					Times(1)
 			}
@@ -247,6 +266,48 @@ func TestHandleAPIPost(t *testing.T) {
 			expectedStatus: fiber.StatusInternalServerError,
 			expectedBody:   `"error":"service error"`,
 		},
+		{
+			name:           "Alias",
+			requestBody:    `{"url":"https://example.com/sale","alias":"spring-sale"}`,
+			serviceReturns: "spring-sale",
+			expectedStatus: fiber.StatusCreated,
+			expectedBody:   `"result":"http://example.com/spring-sale"`,
+		},
+		{
+			name:           "Already shortened",
+			requestBody:    `{"url":"https://example.com/existing"}`,
+			serviceError:   fmt.Errorf("wrapped: %w", &repo.ErrURLConflict{ShortID: "existing1"}),
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   `"result":"http://example.com/existing1"`,
+		},
+		{
+			name:           "Alias taken",
+			requestBody:    `{"url":"https://example.com/sale","alias":"spring-sale"}`,
+			serviceError:   fmt.Errorf("short ID %q: %w", "spring-sale", repo.ErrShortIDTaken),
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   `"result":"http://example.com/spring-sale"`,
+		},
+		{
+			name:           "Invalid alias",
+			requestBody:    `{"url":"https://example.com/sale","alias":"api"}`,
+			serviceError:   fmt.Errorf("%w: %q is reserved", services.ErrInvalidAlias, "api"),
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `is reserved`,
+		},
+		{
+			name:           "Invalid URL",
+			requestBody:    `{"url":"javascript:alert(1)"}`,
+			serviceError:   fmt.Errorf("%w: scheme %q is not allowed, use http or https", services.ErrInvalidURL, "javascript"),
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `is not allowed`,
+		},
+		{
+			name:           "Invalid expiry",
+			requestBody:    `{"url":"https://example.com/sale","ttl_seconds":-1}`,
+			serviceError:   fmt.Errorf("%w: ttl_seconds must be between 1 and 3153600000", services.ErrInvalidExpiry),
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `ttl_seconds must be between 1`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -257,14 +318,10 @@ func TestHandleAPIPost(t *testing.T) {
 			app := fiber.New()
 			app.Post("/api/shorten", controller.HandleAPIPost)
 
-			if strings.Contains(tt.requestBody, `"url":"https://example.com"`) {
-				mockService.EXPECT().
-					ShortenAPIURL(gomock.Any(), &dto.ShortenRequestDTO{URL: "https://example.com"}).
-					Return(tt.serviceReturns, tt.serviceError).
-					Times(1)
-			} else if strings.Contains(tt.requestBody, `"url":"https://example.com/error"`) {
+			var request dto.ShortenRequestDTO
+			if json.Unmarshal([]byte(tt.requestBody), &request) == nil {
 				mockService.EXPECT().
-					ShortenAPIURL(gomock.Any(), &dto.ShortenRequestDTO{URL: "https://example.com/error"}).
+					ShortenAPIURL(gomock.Any(), gomock.Any(), &request).
 					Return(tt.serviceReturns, tt.serviceError).
 					Times(1)
 			}
@@ -290,8 +347,7 @@ func TestHandleGet(t *testing.T) {
 		name           string
 		shortID        string
 		originalURL    string
-		exists         bool
-		ctxError       error
+		serviceError   error
 		expectedStatus int
 		expectedBody   string
 	}{
@@ -299,20 +355,51 @@ func TestHandleGet(t *testing.T) {
 			name:           "Success",
 			shortID:        "abc123",
 			originalURL:    "https://example.com",
-			exists:         true,
-			ctxError:       nil,
 			expectedStatus: fiber.StatusTemporaryRedirect,
 			expectedBody:   "",
 		},
 		{
 			name:           "Not found",
 			shortID:        "notfound",
-			originalURL:    "",
-			exists:         false,
-			ctxError:       nil,
+			serviceError:   services.ErrLinkNotFound,
 			expectedStatus: fiber.StatusNotFound,
 			expectedBody:   "URL not found",
 		},
+		{
+			name:           "Deleted",
+			shortID:        "deleted",
+			serviceError:   fmt.Errorf("error getting original URL: %w", repo.ErrURLDeleted),
+			expectedStatus: fiber.StatusGone,
+			expectedBody:   "URL has been deleted",
+		},
+		{
+			name:           "Expired",
+			shortID:        "expired",
+			serviceError:   fmt.Errorf("error getting original URL: %w", repo.ErrURLExpired),
+			expectedStatus: fiber.StatusGone,
+			expectedBody:   "URL has expired",
+		},
+		{
+			name:           "Timeout",
+			shortID:        "slow",
+			serviceError:   fmt.Errorf("error getting original URL: %w", context.DeadlineExceeded),
+			expectedStatus: fiber.StatusRequestTimeout,
+			expectedBody:   "Request timeout",
+		},
+		{
+			name:           "Canceled",
+			shortID:        "canceled",
+			serviceError:   fmt.Errorf("error getting original URL: %w", context.Canceled),
+			expectedStatus: 499,
+			expectedBody:   "Client closed connection",
+		},
+		{
+			name:           "Storage error",
+			shortID:        "broken",
+			serviceError:   errors.New("db error"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   "Internal Server Error",
+		},
 	}
 
 	for _, tt := range tests {
@@ -323,14 +410,24 @@ func TestHandleGet(t *testing.T) {
 			app := fiber.New()
 			app.Get("/:id", controller.HandleGet)
 
-			if tt.ctxError == nil {
+			mockService.EXPECT().
+				GetOriginalURL(gomock.Any(), tt.shortID).
+				DoAndReturn(func(ctx context.Context, shortID string) (string, error) {
+					_, hasDeadline := ctx.Deadline()
+					assert.True(t, hasDeadline)
+					return tt.originalURL, tt.serviceError
+				}).
+				Times(1)
+			if tt.expectedStatus == fiber.StatusTemporaryRedirect {
+				// Requests of app.Test come from 0.0.0.0.
 				mockService.EXPECT().
-					GetOriginalURL(gomock.Any(), tt.shortID).
-					Return(tt.originalURL, tt.exists).
+					RecordClick(tt.shortID, "https://news.example.com/", "test-agent", "0.0.0.0").
 					Times(1)
 			}
 
 			req := httptest.NewRequest("GET", "/"+tt.shortID, nil)
+			req.Header.Set("Referer", "https://news.example.com/")
+			req.Header.Set("User-Agent", "test-agent")
 			resp, err := app.Test(req)
 			assert.NoError(t, err)
 			defer resp.Body.Close()
@@ -349,3 +446,166 @@ func TestHandleGet(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleAPIGetUserURLs(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		cursor         string
+		limit          int
+		serviceReturns []dto.URLRecord
+		nextCursor     string
+		serviceError   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Success",
+			query:  "?cursor=abc&limit=2",
+			cursor: "abc",
+			limit:  2,
+			serviceReturns: []dto.URLRecord{
+				{ShortURL: "abd", OriginalURL: "https://example.com/1"},
+				{ShortURL: "abe", OriginalURL: "https://example.com/2"},
+			},
+			nextCursor:     "abe",
+			expectedStatus: fiber.StatusOK,
+			expectedBody:   `{"short_url":"http://example.com/abd","original_url":"https://example.com/1"}`,
+		},
+		{
+			name:           "No URLs",
+			serviceReturns: []dto.URLRecord{},
+			expectedStatus: fiber.StatusNoContent,
+		},
+		{
+			name:           "Service error",
+			serviceError:   errors.New("service error"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   `"error":"service error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, mockService, ctrl := setupTestController(t)
+			defer ctrl.Finish()
+
+			app := fiber.New()
+			app.Get("/api/user/urls", controller.HandleAPIGetUserURLs)
+
+			mockService.EXPECT().
+				GetUserURLs(gomock.Any(), gomock.Any(), tt.cursor, tt.limit).
+				Return(tt.serviceReturns, tt.nextCursor, tt.serviceError).
+				Times(1)
+
+			req := httptest.NewRequest("GET", "/api/user/urls"+tt.query, nil)
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.nextCursor, resp.Header.Get("X-Next-Cursor"))
+
+			if tt.expectedBody != "" {
+				body := make([]byte, resp.ContentLength)
+				resp.Body.Read(body)
+				assert.Contains(t, string(body), tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestHandleAPIPostBatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    string
+		serviceReturns []string
+		serviceError   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Success",
+			requestBody: `[{"correlation_id":"1","original_url":"https://example.com","alias":"spring-sale"},
+				{"correlation_id":"2","original_url":"https://example.org"}]`,
+			serviceReturns: []string{"spring-sale", "abc123"},
+			expectedStatus: fiber.StatusCreated,
+			expectedBody:   `{"correlation_id":"2","short_url":"http://example.com/abc123"}`,
+		},
+		{
+			name: "Alias taken",
+			requestBody: `[{"correlation_id":"1","original_url":"https://example.org"},
+				{"correlation_id":"2","original_url":"https://example.com","alias":"spring-sale"}]`,
+			serviceError:   fmt.Errorf("failed to save batch: %w", &repo.BatchItemError{Index: 1, Err: repo.ErrShortIDTaken}),
+			expectedStatus: fiber.StatusConflict,
+			expectedBody:   `"short_url":"http://example.com/spring-sale"`,
+		},
+		{
+			name:           "Invalid alias",
+			requestBody:    `[{"correlation_id":"1","original_url":"https://example.com","alias":"ping"}]`,
+			serviceError:   &repo.BatchItemError{Index: 0, Err: fmt.Errorf("%w: %q is reserved", services.ErrInvalidAlias, "ping")},
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `"correlation_id":"1"`,
+		},
+		{
+			name: "Invalid URL",
+			requestBody: `[{"correlation_id":"1","original_url":"https://example.com"},
+				{"correlation_id":"2","original_url":"www.example2.com"}]`,
+			serviceError:   &repo.BatchItemError{Index: 1, Err: fmt.Errorf("%w: it must start with http:// or https://", services.ErrInvalidURL)},
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `"correlation_id":"2"`,
+		},
+		{
+			name:           "Invalid expiry",
+			requestBody:    `[{"correlation_id":"1","original_url":"https://example.com","expires_at":"2020-01-01T00:00:00Z"}]`,
+			serviceError:   &repo.BatchItemError{Index: 0, Err: fmt.Errorf("%w: expires_at 2020-01-01T00:00:00Z is not in the future", services.ErrInvalidExpiry)},
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `"correlation_id":"1"`,
+		},
+		{
+			name:           "Service error",
+			requestBody:    `[{"correlation_id":"1","original_url":"https://example.com"}]`,
+			serviceError:   errors.New("db error"),
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedBody:   `"error":"db error"`,
+		},
+		{
+			name:           "Empty batch",
+			requestBody:    `[]`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedBody:   `"error":"empty batch"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, mockService, ctrl := setupTestController(t)
+			defer ctrl.Finish()
+
+			app := fiber.New()
+			app.Post("/api/shorten/batch", controller.HandleAPIPostBatch)
+
+			var requests []dto.BatchRequestDTO
+			assert.NoError(t, json.Unmarshal([]byte(tt.requestBody), &requests))
+			if len(requests) > 0 {
+				mockService.EXPECT().
+					BatchShortenURLs(gomock.Any(), gomock.Any(), requests).
+					Return(tt.serviceReturns, tt.serviceError).
+					Times(1)
+			}
+
+			req := httptest.NewRequest("POST", "/api/shorten/batch", strings.NewReader(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			body := make([]byte, resp.ContentLength)
+			resp.Body.Read(body)
+			assert.Contains(t, string(body), tt.expectedBody)
+		})
+	}
+}
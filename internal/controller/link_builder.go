@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LinkBuilder builds the public short links returned to clients.
+//
+// With a configured base URL, such as https://go.example.com/s/, links are
+// that URL followed by the short ID. Without one they are built from the
+// request, where fiber honors the X-Forwarded-Host and X-Forwarded-Proto
+// headers of trusted proxies only.
+type LinkBuilder struct {
+	baseURL string
+}
+
+func NewLinkBuilder(cfg *config.Config) *LinkBuilder {
+	return &LinkBuilder{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+	}
+}
+
+// Base returns the URL short IDs are appended to, without a trailing slash.
+func (b *LinkBuilder) Base(ctx *fiber.Ctx) string {
+	if b.baseURL != "" {
+		return b.baseURL
+	}
+	return ctx.BaseURL()
+}
+
+// Link returns the short link of shortID.
+func (b *LinkBuilder) Link(ctx *fiber.Ctx, shortID string) string {
+	return JoinLink(b.Base(ctx), shortID)
+}
+
+// JoinLink appends shortID to a base returned by Base, for code that builds
+// links once the request is gone.
+func JoinLink(base, shortID string) string {
+	return base + "/" + shortID
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkBuilder(t *testing.T) {
+	tests := []struct {
+		name           string
+		baseURL        string
+		trustedProxies []string
+		headers        map[string]string
+		expectedLink   string
+	}{
+		{
+			name:         "Configured base URL",
+			baseURL:      "https://go.example.com",
+			headers:      map[string]string{"X-Forwarded-Host": "evil.example.com"},
+			expectedLink: "https://go.example.com/abc",
+		},
+		{
+			name:         "Configured base URL with a path prefix",
+			baseURL:      "https://go.example.com/s/",
+			expectedLink: "https://go.example.com/s/abc",
+		},
+		{
+			name:         "Request host",
+			expectedLink: "http://example.com/abc",
+		},
+		{
+			name:         "Forwarded headers of an untrusted client",
+			headers:      map[string]string{"X-Forwarded-Host": "evil.example.com", "X-Forwarded-Proto": "https"},
+			expectedLink: "http://example.com/abc",
+		},
+		{
+			name: "Forwarded headers of a trusted proxy",
+			// Requests of app.Test come from 0.0.0.0.
+			trustedProxies: []string{"0.0.0.0"},
+			headers:        map[string]string{"X-Forwarded-Host": "go.example.com", "X-Forwarded-Proto": "https"},
+			expectedLink:   "https://go.example.com/abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := NewLinkBuilder(&config.Config{BaseURL: tt.baseURL})
+			app := fiber.New(fiber.Config{
+				EnableTrustedProxyCheck: true,
+				TrustedProxies:          tt.trustedProxies,
+			})
+			app.Get("/:id", func(ctx *fiber.Ctx) error {
+				return ctx.SendString(links.Link(ctx, ctx.Params("id")))
+			})
+
+			req := httptest.NewRequest("GET", "/abc", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedLink, string(body))
+		})
+	}
+}
@@ -1,7 +1,25 @@
 package dto
 
+import "time"
+
 type URLRecord struct {
 	UUID        string `json:"uuid"`
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
+	UserID      string `json:"user_id"`
+	IsDeleted   bool   `json:"is_deleted"`
+	// CreatedAt is zero for links stored before creation times were kept.
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for links that never expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// ClickEvent is one redirect of a short URL. IPHash is a keyed hash of the
+// visitor's IP address, which is never stored as is.
+type ClickEvent struct {
+	ShortURL  string    `json:"short_url"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash"`
 }
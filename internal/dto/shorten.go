@@ -1,16 +1,28 @@
 package dto
 
+import "time"
+
+// ShortenRequestDTO is a link to shorten. ExpiresAt and TTLSeconds are
+// exclusive ways to make the link expire; without them it never does.
 type ShortenRequestDTO struct {
-	URL string `json:"url"`
+	URL        string     `json:"url"`
+	Alias      string     `json:"alias,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
 }
 
 type ShortenResponseDTO struct {
 	Result string `json:"result"`
 }
 
+// BatchRequestDTO is one link of a batch or an import, with the same
+// optional alias and expiry as ShortenRequestDTO.
 type BatchRequestDTO struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
 }
 
 type BatchResponseDTO struct {
@@ -18,6 +30,39 @@ type BatchResponseDTO struct {
 	ShortURL      string `json:"short_url"`
 }
 
+// ImportResult is the outcome of one request of an import chunk: either the
+// short ID it is stored under or the error that rejected it.
+type ImportResult struct {
+	ShortID string
+	Err     error
+}
+
+// ImportResultDTO is one NDJSON line of an import response. Line is the
+// 1-based line of the request it answers.
+type ImportResultDTO struct {
+	Line          int    `json:"line"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ExportRecordDTO is one link of an export. CreatedAt is RFC 3339, or empty
+// for links stored before creation times were kept. ExpiresAt is RFC 3339,
+// or empty for links that never expire.
+type ExportRecordDTO struct {
+	ShortID     string `json:"short_id"`
+	OriginalURL string `json:"original_url"`
+	UserID      string `json:"user_id"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at"`
+	IsDeleted   bool   `json:"is_deleted"`
+}
+
+type UserURLResponseDTO struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
 type DeleteURLsRequestDTO struct {
 	URLIDs []string `json:"-"`
 }
@@ -0,0 +1,62 @@
+package dto
+
+import "time"
+
+// StatsBucket is the width of the buckets of a click time series.
+type StatsBucket string
+
+const (
+	StatsBucketHour StatsBucket = "hour"
+	StatsBucketDay  StatsBucket = "day"
+)
+
+// Duration returns the width of the bucket, or zero for an unknown bucket.
+func (b StatsBucket) Duration() time.Duration {
+	switch b {
+	case StatsBucketHour:
+		return time.Hour
+	case StatsBucketDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// StatsQuery selects the clicks in [From, To), counted per Bucket, with the
+// Top most frequent referrers and user agents.
+type StatsQuery struct {
+	Bucket StatsBucket
+	From   time.Time
+	To     time.Time
+	Top    int
+}
+
+// ClickStats aggregates the clicks of a link. Unique visitors are told apart
+// by their hashed IP address.
+type ClickStats struct {
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Series         []SeriesPoint `json:"series"`
+	TopReferrers   []ValueCount  `json:"top_referrers"`
+	TopUserAgents  []ValueCount  `json:"top_user_agents"`
+}
+
+// SeriesPoint counts the clicks of the bucket starting at Start, in UTC.
+type SeriesPoint struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// ValueCount counts the clicks with a given referrer or user agent.
+type ValueCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// LinkStatsDTO is the response of the statistics endpoint.
+type LinkStatsDTO struct {
+	ShortID string      `json:"short_id"`
+	Bucket  StatsBucket `json:"bucket"`
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	ClickStats
+}
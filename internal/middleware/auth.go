@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	UserIDCookieName = "user_id"
+	userIDLocalsKey  = "userID"
+	newUserLocalsKey = "newUser"
+	userCookieMaxAge = 365 * 24 * time.Hour
+)
+
+// MiddlewareAuth identifies the caller by an HMAC-signed user ID cookie.
+// When the cookie is missing or its signature does not match, a new user ID
+// is issued and set as a cookie on the response.
+func MiddlewareAuth(secretKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := verifyUserID(c.Cookies(UserIDCookieName), secretKey)
+		if !ok {
+			userID = uuid.New().String()
+			c.Cookie(&fiber.Cookie{
+				Name:     UserIDCookieName,
+				Value:    signUserID(userID, secretKey),
+				Path:     "/",
+				Expires:  time.Now().Add(userCookieMaxAge),
+				HTTPOnly: true,
+				SameSite: fiber.CookieSameSiteLaxMode,
+			})
+			log.Debug().Str("userID", userID).Msg("issued new user ID cookie")
+		}
+
+		c.Locals(userIDLocalsKey, userID)
+		c.Locals(newUserLocalsKey, !ok)
+		return c.Next()
+	}
+}
+
+// RequireUserID rejects requests that did not present a valid user ID
+// cookie. It must run after MiddlewareAuth.
+func RequireUserID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		newUser, _ := c.Locals(newUserLocalsKey).(bool)
+		if GetUserID(c) == "" || newUser {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireAdminToken only lets through requests that carry adminToken as a
+// bearer token. With an empty adminToken the admin endpoints are disabled.
+func RequireAdminToken(adminToken string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if adminToken == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "admin endpoints are disabled",
+			})
+		}
+
+		token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !found || !hmac.Equal([]byte(token), []byte(adminToken)) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// GetUserID returns the user ID resolved by MiddlewareAuth, or an empty
+// string when the middleware did not run for the request.
+func GetUserID(c *fiber.Ctx) string {
+	userID, _ := c.Locals(userIDLocalsKey).(string)
+	return userID
+}
+
+func signUserID(userID, secretKey string) string {
+	return userID + "." + userIDSignature(userID, secretKey)
+}
+
+func verifyUserID(cookie, secretKey string) (string, bool) {
+	userID, signature, found := strings.Cut(cookie, ".")
+	if !found || userID == "" {
+		return "", false
+	}
+
+	expected := userIDSignature(userID, secretKey)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", false
+	}
+	return userID, true
+}
+
+func userIDSignature(userID, secretKey string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
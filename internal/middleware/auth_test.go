@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecretKey = "test-secret"
+
+func setupAuthApp() *fiber.App {
+	app := fiber.New()
+	app.Use(MiddlewareAuth(testSecretKey))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(GetUserID(c))
+	})
+	return app
+}
+
+func findUserCookie(resp *http.Response) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == UserIDCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestMiddlewareAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		cookie       string
+		expectIssued bool
+		expectedUser string
+	}{
+		{
+			name:         "No cookie",
+			cookie:       "",
+			expectIssued: true,
+		},
+		{
+			name:         "Valid cookie",
+			cookie:       signUserID("user-1", testSecretKey),
+			expectIssued: false,
+			expectedUser: "user-1",
+		},
+		{
+			name:         "Tampered user ID",
+			cookie:       "user-2." + userIDSignature("user-1", testSecretKey),
+			expectIssued: true,
+		},
+		{
+			name:         "Signed with another key",
+			cookie:       signUserID("user-1", "other-secret"),
+			expectIssued: true,
+		},
+		{
+			name:         "Malformed cookie",
+			cookie:       "garbage",
+			expectIssued: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupAuthApp()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: UserIDCookieName, Value: tt.cookie})
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			userID := string(body)
+			assert.NotEmpty(t, userID)
+
+			issued := findUserCookie(resp)
+			if tt.expectIssued {
+				require.NotNil(t, issued)
+				assert.True(t, issued.HttpOnly)
+				verifiedID, ok := verifyUserID(issued.Value, testSecretKey)
+				assert.True(t, ok)
+				assert.Equal(t, userID, verifiedID)
+				assert.NotEqual(t, tt.expectedUser, userID)
+			} else {
+				assert.Nil(t, issued)
+				assert.Equal(t, tt.expectedUser, userID)
+			}
+		})
+	}
+}
+
+func TestGetUserIDWithoutMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(GetUserID(c))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, string(body))
+}
+
+func TestRequireUserID(t *testing.T) {
+	tests := []struct {
+		name           string
+		cookie         string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid cookie",
+			cookie:         signUserID("user-1", testSecretKey),
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "No cookie",
+			cookie:         "",
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Invalid cookie",
+			cookie:         signUserID("user-1", "other-secret"),
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(MiddlewareAuth(testSecretKey))
+			app.Get("/", RequireUserID(), func(c *fiber.Ctx) error {
+				return c.SendString(GetUserID(c))
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: UserIDCookieName, Value: tt.cookie})
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		adminToken     string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Valid token", adminToken: "s3cret", authorization: "Bearer s3cret", expectedStatus: fiber.StatusOK},
+		{name: "Wrong token", adminToken: "s3cret", authorization: "Bearer guess", expectedStatus: fiber.StatusUnauthorized},
+		{name: "Missing token", adminToken: "s3cret", expectedStatus: fiber.StatusUnauthorized},
+		{name: "Not a bearer token", adminToken: "s3cret", authorization: "s3cret", expectedStatus: fiber.StatusUnauthorized},
+		{name: "Disabled", authorization: "Bearer ", expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", RequireAdminToken(tt.adminToken), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MaxBodySize rejects request bodies larger than limit bytes with 413. The
+// server streams request bodies so that imports are not bound by its
+// BodyLimit, which then no longer rejects anything; routes that read the
+// whole body must use this middleware instead.
+func MaxBodySize(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		contentLength := c.Request().Header.ContentLength()
+		if contentLength > limit {
+			return bodyTooLarge(c)
+		}
+
+		// Chunked bodies have no length up front: read them here, bounded.
+		stream := c.Context().RequestBodyStream()
+		if contentLength < 0 && stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "failed to read request body",
+				})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c)
+			}
+			c.Request().SetBodyRaw(body)
+		}
+		return c.Next()
+	}
+}
+
+func bodyTooLarge(c *fiber.Ctx) error {
+	// The rest of the body is left unread on the connection.
+	c.Context().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"error": "request body too large",
+	})
+}
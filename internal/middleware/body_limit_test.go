@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	const limit = 16
+
+	tests := []struct {
+		name           string
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{name: "Within limit", body: "short body", expectedStatus: fiber.StatusOK},
+		{name: "Over limit", body: strings.Repeat("a", limit+1), expectedStatus: fiber.StatusRequestEntityTooLarge},
+		{name: "Chunked within limit", body: "short body", chunked: true, expectedStatus: fiber.StatusOK},
+		{name: "Chunked over limit", body: strings.Repeat("a", limit+1), chunked: true, expectedStatus: fiber.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{BodyLimit: 4, StreamRequestBody: true})
+			app.Post("/", MaxBodySize(limit), func(c *fiber.Ctx) error {
+				return c.Send(c.Body())
+			})
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus == fiber.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+			}
+		})
+	}
+}
@@ -14,12 +14,17 @@ func MiddlewareZerolog() fiber.Handler {
 		err := c.Next()
 
 		duration := time.Since(start)
+		// Reading a streamed body here would buffer all of it.
+		responseLength := -1
+		if !c.Response().IsBodyStream() {
+			responseLength = len(c.Response().Body())
+		}
 		log.Info().
 			Str("method", c.Method()).
 			Str("uri", c.Path()).
 			Str("duration", duration.String()).
 			Int("status", c.Response().StatusCode()).
-			Int("response length", len(c.Response().Body())).
+			Int("response length", responseLength).
 			Msg("request processed")
 
 		return err
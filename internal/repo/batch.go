@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// WithCreatedAt returns a copy of records where those without a creation
+// time are stamped with now. Records copied from another backend keep theirs.
+func WithCreatedAt(records []dto.URLRecord, now time.Time) []dto.URLRecord {
+	stamped := make([]dto.URLRecord, len(records))
+	for i, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+		stamped[i] = record
+	}
+	return stamped
+}
+
+// PlanBatch resolves a batch for the in-process backends. It returns the
+// records to report back from SaveURLBatch, where live records whose original
+// URL is already stored live, or repeated within the batch, take the existing
+// short URL, and the new records to insert. Deleted records are never
+// deduplicated. shortIDTaken and shortIDByOriginal look up the current
+// contents of the store, shortIDByOriginal leaving out expired records.
+func PlanBatch(
+	records []dto.URLRecord,
+	shortIDTaken func(shortID string) bool,
+	shortIDByOriginal func(originalURL string) (string, bool),
+) (saved, inserts []dto.URLRecord, err error) {
+	saved = make([]dto.URLRecord, len(records))
+	newShortIDs := make(map[string]bool, len(records))
+	newOriginals := make(map[string]string, len(records))
+
+	for i, record := range records {
+		if !record.IsDeleted {
+			if shortID, exists := shortIDByOriginal(record.OriginalURL); exists {
+				record.ShortURL = shortID
+				saved[i] = record
+				continue
+			}
+			if shortID, exists := newOriginals[record.OriginalURL]; exists {
+				record.ShortURL = shortID
+				saved[i] = record
+				continue
+			}
+		}
+		if newShortIDs[record.ShortURL] || shortIDTaken(record.ShortURL) {
+			return nil, nil, &BatchItemError{Index: i, Err: ErrShortIDTaken}
+		}
+
+		newShortIDs[record.ShortURL] = true
+		if !record.IsDeleted {
+			newOriginals[record.OriginalURL] = record.ShortURL
+		}
+		saved[i] = record
+		inserts = append(inserts, record)
+	}
+	return saved, inserts, nil
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+)
+
+// CachedRepository is a read-through cache of GetOriginalURL in front of
+// another repository, so that redirects of popular links do not reach the
+// database. It keeps up to size entries, evicting the least recently used.
+//
+// Resolved links, and deleted or expired ones, stay cached for ttl, but never
+// past the link's own expiry time; short URLs that do not exist stay cached
+// for negativeTTL. Storage errors are not cached. Writes made through the
+// cache invalidate the entries they touch, but changes made by other
+// processes are only seen once the entry's TTL runs out.
+//
+// Every other method goes straight to the wrapped repository.
+type CachedRepository struct {
+	next        repo.IURLRepository
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the entries, most recently used first.
+	order *list.List
+	// generation is bumped by every invalidation, so that a lookup that
+	// raced with one does not cache what it read before the write.
+	generation uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type entry struct {
+	shortID       string
+	originalURL   string
+	linkExpiresAt time.Time
+	exists        bool
+	err           error
+	// expiresAt is when the entry stops being served.
+	expiresAt time.Time
+}
+
+// Stats is a snapshot of the cache counters.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+func NewCachedRepository(next repo.IURLRepository, size int, ttl, negativeTTL time.Duration) *CachedRepository {
+	return &CachedRepository{
+		next:        next,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Stats returns the lookups answered from the cache and from the wrapped
+// repository since the start, and the number of cached entries.
+func (c *CachedRepository) Stats() Stats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: entries}
+}
+
+func (c *CachedRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+	if cached, ok := c.get(shortID); ok {
+		c.hits.Add(1)
+		return cached.originalURL, cached.linkExpiresAt, cached.exists, cached.err
+	}
+	c.misses.Add(1)
+
+	generation := c.currentGeneration()
+	originalURL, linkExpiresAt, exists, err := c.next.GetOriginalURL(ctx, shortID)
+
+	ttl := c.ttl
+	switch {
+	case err != nil && !errors.Is(err, repo.ErrURLDeleted) && !errors.Is(err, repo.ErrURLExpired):
+		return originalURL, linkExpiresAt, exists, err
+	case err == nil && !exists:
+		ttl = c.negativeTTL
+	}
+	if ttl > 0 {
+		expiresAt := c.now().Add(ttl)
+		if !linkExpiresAt.IsZero() && linkExpiresAt.Before(expiresAt) {
+			expiresAt = linkExpiresAt
+		}
+		c.set(generation, entry{
+			// Callers may pass strings backed by a reused request buffer.
+			shortID:       strings.Clone(shortID),
+			originalURL:   originalURL,
+			linkExpiresAt: linkExpiresAt,
+			exists:        exists,
+			err:           err,
+			expiresAt:     expiresAt,
+		})
+	}
+	return originalURL, linkExpiresAt, exists, err
+}
+
+func (c *CachedRepository) SaveShortID(
+	ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time,
+) error {
+	defer c.invalidate(shortID)
+	return c.next.SaveShortID(ctx, userID, shortID, originalURL, expiresAt)
+}
+
+func (c *CachedRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	shortIDs := make([]string, len(records))
+	for i, record := range records {
+		shortIDs[i] = record.ShortURL
+	}
+	defer c.invalidate(shortIDs...)
+	return c.next.SaveURLBatch(ctx, records)
+}
+
+func (c *CachedRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	defer c.invalidate(shortURLs...)
+	return c.next.BatchDeleteURLs(ctx, userID, shortURLs)
+}
+
+// ExpireURLs does not tell which links it tombstoned, so the whole cache is
+// dropped whenever it tombstones any.
+func (c *CachedRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	n, err := c.next.ExpireURLs(ctx, now, limit)
+	if n > 0 {
+		c.purge()
+	}
+	return n, err
+}
+
+func (c *CachedRepository) GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	return c.next.GetShortIDByOriginalURL(ctx, originalURL)
+}
+
+func (c *CachedRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	return c.next.GetURLOwner(ctx, shortID)
+}
+
+func (c *CachedRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	return c.next.GetUserURLs(ctx, userID, cursor, limit)
+}
+
+func (c *CachedRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	return c.next.ListURLs(ctx, cursor, limit)
+}
+
+func (c *CachedRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
+	return c.next.SaveClicks(ctx, clicks)
+}
+
+func (c *CachedRepository) GetClickStats(
+	ctx context.Context, shortID string, query dto.StatsQuery,
+) (dto.ClickStats, error) {
+	return c.next.GetClickStats(ctx, shortID, query)
+}
+
+func (c *CachedRepository) Ping(ctx context.Context) error {
+	return c.next.Ping(ctx)
+}
+
+// get returns the live entry of shortID and marks it as recently used.
+func (c *CachedRepository) get(shortID string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[shortID]
+	if !ok {
+		return entry{}, false
+	}
+	cached := element.Value.(entry)
+	if !c.now().Before(cached.expiresAt) {
+		c.remove(element)
+		return entry{}, false
+	}
+	c.order.MoveToFront(element)
+	return cached, true
+}
+
+// set stores an entry read at generation, unless an invalidation happened
+// since, evicting the least recently used entry when the cache is full.
+func (c *CachedRepository) set(generation uint64, cached entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[cached.shortID]; ok {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[cached.shortID] = c.order.PushFront(cached)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *CachedRepository) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// invalidate drops the entries of shortIDs. It runs after the write, so
+// that no lookup can cache what the write replaced.
+func (c *CachedRepository) invalidate(shortIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, shortID := range shortIDs {
+		if element, ok := c.entries[shortID]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *CachedRepository) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *CachedRepository) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(entry).shortID)
+}
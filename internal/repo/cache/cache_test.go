@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	testShortID     = "abc123"
+	testOriginalURL = "https://example.com"
+	testUserID      = "user-1"
+)
+
+func setupTestCache(t *testing.T, size int) (*CachedRepository, *mocks.MockIURLRepository, *time.Time) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewCachedRepository(mockRepo, size, time.Minute, 10*time.Second)
+	c.now = func() time.Time { return now }
+	return c, mockRepo, &now
+}
+
+func assertLookup(t *testing.T, c *CachedRepository, shortID, expectedURL string, expectedExists bool, expectedErr error) {
+	t.Helper()
+	originalURL, _, exists, err := c.GetOriginalURL(context.Background(), shortID)
+	assert.Equal(t, expectedURL, originalURL)
+	assert.Equal(t, expectedExists, exists)
+	assert.ErrorIs(t, err, expectedErr)
+}
+
+func TestGetOriginalURLIsCached(t *testing.T) {
+	tests := []struct {
+		name        string
+		originalURL string
+		exists      bool
+		err         error
+	}{
+		{name: "Found", originalURL: testOriginalURL, exists: true},
+		{name: "Not found", exists: false},
+		{name: "Deleted", err: repo.ErrURLDeleted},
+		{name: "Expired", err: repo.ErrURLExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockRepo, _ := setupTestCache(t, 10)
+			mockRepo.EXPECT().
+				GetOriginalURL(gomock.Any(), testShortID).
+				Return(tt.originalURL, time.Time{}, tt.exists, tt.err).
+				Times(1)
+
+			for range 3 {
+				assertLookup(t, c, testShortID, tt.originalURL, tt.exists, tt.err)
+			}
+			assert.Equal(t, Stats{Hits: 2, Misses: 1, Entries: 1}, c.Stats())
+		})
+	}
+}
+
+func TestGetOriginalURLDoesNotCacheErrors(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 10)
+	dbErr := errors.New("db error")
+	gomock.InOrder(
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return("", time.Time{}, false, dbErr),
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return(testOriginalURL, time.Time{}, true, nil),
+	)
+
+	assertLookup(t, c, testShortID, "", false, dbErr)
+	assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+	assert.Equal(t, Stats{Hits: 0, Misses: 2, Entries: 1}, c.Stats())
+}
+
+func TestGetOriginalURLTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		ttl    time.Duration
+	}{
+		{name: "Found", exists: true, ttl: time.Minute},
+		{name: "Not found", exists: false, ttl: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockRepo, now := setupTestCache(t, 10)
+			mockRepo.EXPECT().
+				GetOriginalURL(gomock.Any(), testShortID).
+				Return("", time.Time{}, tt.exists, nil).
+				Times(2)
+
+			c.GetOriginalURL(context.Background(), testShortID)
+			*now = now.Add(tt.ttl - time.Nanosecond)
+			c.GetOriginalURL(context.Background(), testShortID)
+			*now = now.Add(time.Nanosecond)
+			c.GetOriginalURL(context.Background(), testShortID)
+
+			assert.Equal(t, Stats{Hits: 1, Misses: 2, Entries: 1}, c.Stats())
+		})
+	}
+}
+
+func TestGetOriginalURLTTLCappedAtLinkExpiry(t *testing.T) {
+	c, mockRepo, now := setupTestCache(t, 10)
+	linkExpiresAt := now.Add(10 * time.Second)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return(testOriginalURL, linkExpiresAt, true, nil),
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return("", time.Time{}, false, repo.ErrURLExpired),
+	)
+
+	originalURL, expiresAt, exists, err := c.GetOriginalURL(context.Background(), testShortID)
+	require.NoError(t, err)
+	assert.Equal(t, testOriginalURL, originalURL)
+	assert.Equal(t, linkExpiresAt, expiresAt)
+	assert.True(t, exists)
+
+	*now = linkExpiresAt.Add(-time.Nanosecond)
+	_, expiresAt, _, _ = c.GetOriginalURL(context.Background(), testShortID)
+	assert.Equal(t, linkExpiresAt, expiresAt, "hits return the link's expiry too")
+	// The link expires well before the cache TTL runs out.
+	*now = linkExpiresAt
+	assertLookup(t, c, testShortID, "", false, repo.ErrURLExpired)
+	assert.Equal(t, Stats{Hits: 1, Misses: 2, Entries: 1}, c.Stats())
+}
+
+func TestGetOriginalURLWithoutNegativeTTL(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 10)
+	c.negativeTTL = 0
+	mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return("", time.Time{}, false, nil).Times(2)
+
+	assertLookup(t, c, testShortID, "", false, nil)
+	assertLookup(t, c, testShortID, "", false, nil)
+	assert.Equal(t, 0, c.Stats().Entries)
+}
+
+func TestGetOriginalURLEvictsLeastRecentlyUsed(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 2)
+	for _, shortID := range []string{"a", "b", "c"} {
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), shortID).Return("https://"+shortID, time.Time{}, true, nil).Times(1)
+	}
+	mockRepo.EXPECT().GetOriginalURL(gomock.Any(), "b").Return("https://b", time.Time{}, true, nil).Times(1)
+
+	assertLookup(t, c, "a", "https://a", true, nil)
+	assertLookup(t, c, "b", "https://b", true, nil)
+	// Using a makes b the least recently used entry.
+	assertLookup(t, c, "a", "https://a", true, nil)
+	assertLookup(t, c, "c", "https://c", true, nil)
+
+	assertLookup(t, c, "a", "https://a", true, nil)
+	assertLookup(t, c, "c", "https://c", true, nil)
+	assertLookup(t, c, "b", "https://b", true, nil)
+	assert.Equal(t, Stats{Hits: 3, Misses: 4, Entries: 2}, c.Stats())
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		write func(c *CachedRepository, mockRepo *mocks.MockIURLRepository) error
+	}{
+		{
+			name: "SaveShortID",
+			write: func(c *CachedRepository, mockRepo *mocks.MockIURLRepository) error {
+				mockRepo.EXPECT().
+					SaveShortID(ctx, testUserID, testShortID, testOriginalURL, time.Time{}).
+					Return(nil)
+				return c.SaveShortID(ctx, testUserID, testShortID, testOriginalURL, time.Time{})
+			},
+		},
+		{
+			name: "SaveURLBatch",
+			write: func(c *CachedRepository, mockRepo *mocks.MockIURLRepository) error {
+				records := []dto.URLRecord{{ShortURL: "other"}, {ShortURL: testShortID}}
+				mockRepo.EXPECT().SaveURLBatch(ctx, records).Return(records, nil)
+				_, err := c.SaveURLBatch(ctx, records)
+				return err
+			},
+		},
+		{
+			name: "BatchDeleteURLs",
+			write: func(c *CachedRepository, mockRepo *mocks.MockIURLRepository) error {
+				mockRepo.EXPECT().BatchDeleteURLs(ctx, testUserID, []string{testShortID}).Return(nil)
+				return c.BatchDeleteURLs(ctx, testUserID, []string{testShortID})
+			},
+		},
+		{
+			name: "ExpireURLs",
+			write: func(c *CachedRepository, mockRepo *mocks.MockIURLRepository) error {
+				mockRepo.EXPECT().ExpireURLs(ctx, gomock.Any(), 100).Return(1, nil)
+				_, err := c.ExpireURLs(ctx, time.Now(), 100)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mockRepo, _ := setupTestCache(t, 10)
+			gomock.InOrder(
+				mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return("", time.Time{}, false, nil),
+				mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return(testOriginalURL, time.Time{}, true, nil),
+			)
+
+			assertLookup(t, c, testShortID, "", false, nil)
+			require.NoError(t, tt.write(c, mockRepo))
+			assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+		})
+	}
+}
+
+func TestExpireURLsKeepsCacheWhenNothingExpired(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 10)
+	mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return(testOriginalURL, time.Time{}, true, nil).Times(1)
+	mockRepo.EXPECT().ExpireURLs(gomock.Any(), gomock.Any(), 100).Return(0, nil)
+
+	assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+	_, err := c.ExpireURLs(context.Background(), time.Now(), 100)
+	require.NoError(t, err)
+	assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+}
+
+func TestGetOriginalURLRacingWithWrite(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 10)
+	ctx := context.Background()
+	gomock.InOrder(
+		// The link is deleted between the read and the caching of its result.
+		mockRepo.EXPECT().
+			GetOriginalURL(gomock.Any(), testShortID).
+			DoAndReturn(func(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+				require.NoError(t, c.BatchDeleteURLs(ctx, testUserID, []string{testShortID}))
+				return testOriginalURL, time.Time{}, true, nil
+			}),
+		mockRepo.EXPECT().BatchDeleteURLs(ctx, testUserID, []string{testShortID}).Return(nil),
+		mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return("", time.Time{}, false, repo.ErrURLDeleted),
+	)
+
+	assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+	assertLookup(t, c, testShortID, "", false, repo.ErrURLDeleted)
+}
+
+func TestGetOriginalURLCopiesShortID(t *testing.T) {
+	c, mockRepo, _ := setupTestCache(t, 10)
+	mockRepo.EXPECT().GetOriginalURL(gomock.Any(), testShortID).Return(testOriginalURL, time.Time{}, true, nil).Times(1)
+
+	// Like Fiber's route parameters, the short ID is backed by a buffer that
+	// is reused once the request is over.
+	buf := []byte(testShortID)
+	assertLookup(t, c, unsafe.String(&buf[0], len(buf)), testOriginalURL, true, nil)
+	copy(buf, "zzzzzz")
+
+	assertLookup(t, c, testShortID, testOriginalURL, true, nil)
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"sort"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// AggregateClicks computes the statistics of the clicks of one link, for the
+// backends without a query language. It follows the SQL backends: the series
+// only holds the non-empty buckets, in order, and the top lists are sorted by
+// clicks then value, leaving empty values out.
+func AggregateClicks(clicks []dto.ClickEvent, query dto.StatsQuery) dto.ClickStats {
+	var (
+		stats      dto.ClickStats
+		visitors   = make(map[string]bool)
+		buckets    = make(map[int64]int64)
+		referrers  = make(map[string]int64)
+		userAgents = make(map[string]int64)
+		width      = query.Bucket.Duration()
+	)
+	for _, click := range clicks {
+		if click.ClickedAt.Before(query.From) || !click.ClickedAt.Before(query.To) {
+			continue
+		}
+		stats.TotalClicks++
+		visitors[click.IPHash] = true
+		buckets[click.ClickedAt.UTC().Truncate(width).Unix()]++
+		if click.Referrer != "" {
+			referrers[click.Referrer]++
+		}
+		if click.UserAgent != "" {
+			userAgents[click.UserAgent]++
+		}
+	}
+	stats.UniqueVisitors = int64(len(visitors))
+
+	stats.Series = make([]dto.SeriesPoint, 0, len(buckets))
+	for start, n := range buckets {
+		stats.Series = append(stats.Series, dto.SeriesPoint{Start: time.Unix(start, 0).UTC(), Clicks: n})
+	}
+	sort.Slice(stats.Series, func(i, j int) bool {
+		return stats.Series[i].Start.Before(stats.Series[j].Start)
+	})
+
+	stats.TopReferrers = topValues(referrers, query.Top)
+	stats.TopUserAgents = topValues(userAgents, query.Top)
+	return stats
+}
+
+func topValues(counts map[string]int64, top int) []dto.ValueCount {
+	values := make([]dto.ValueCount, 0, len(counts))
+	for value, n := range counts {
+		values = append(values, dto.ValueCount{Value: value, Clicks: n})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Clicks != values[j].Clicks {
+			return values[i].Clicks > values[j].Clicks
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > top {
+		values = values[:top]
+	}
+	return values
+}
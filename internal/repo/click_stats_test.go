@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateClicks(t *testing.T) {
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	clicks := []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: base.Add(5 * time.Minute), Referrer: "https://r1.example/", UserAgent: "u1", IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: base.Add(30 * time.Minute), Referrer: "https://r1.example/", UserAgent: "u2", IPHash: "h2"},
+		// Clicks times are compared as instants, whatever their zone.
+		{ShortURL: "a", ClickedAt: base.Add(70 * time.Minute).In(time.FixedZone("UTC+5", 5*60*60)), Referrer: "https://r2.example/", UserAgent: "u1", IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: base.Add(25 * time.Hour), UserAgent: "u1", IPHash: "h3"},
+		{ShortURL: "a", ClickedAt: base.Add(-time.Hour), Referrer: "https://r3.example/", IPHash: "h4"},
+		{ShortURL: "a", ClickedAt: base.Add(48 * time.Hour), Referrer: "https://r3.example/", IPHash: "h4"},
+	}
+
+	tests := []struct {
+		name     string
+		query    dto.StatsQuery
+		expected dto.ClickStats
+	}{
+		{
+			name:  "Hourly",
+			query: dto.StatsQuery{Bucket: dto.StatsBucketHour, From: base, To: base.Add(48 * time.Hour), Top: 1},
+			expected: dto.ClickStats{
+				TotalClicks:    4,
+				UniqueVisitors: 3,
+				Series: []dto.SeriesPoint{
+					{Start: base, Clicks: 2},
+					{Start: base.Add(time.Hour), Clicks: 1},
+					{Start: base.Add(25 * time.Hour), Clicks: 1},
+				},
+				TopReferrers:  []dto.ValueCount{{Value: "https://r1.example/", Clicks: 2}},
+				TopUserAgents: []dto.ValueCount{{Value: "u1", Clicks: 3}},
+			},
+		},
+		{
+			name:  "Daily",
+			query: dto.StatsQuery{Bucket: dto.StatsBucketDay, From: base, To: base.Add(48 * time.Hour), Top: 10},
+			expected: dto.ClickStats{
+				TotalClicks:    4,
+				UniqueVisitors: 3,
+				Series: []dto.SeriesPoint{
+					{Start: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Clicks: 3},
+					{Start: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), Clicks: 1},
+				},
+				TopReferrers: []dto.ValueCount{
+					{Value: "https://r1.example/", Clicks: 2},
+					{Value: "https://r2.example/", Clicks: 1},
+				},
+				TopUserAgents: []dto.ValueCount{{Value: "u1", Clicks: 3}, {Value: "u2", Clicks: 1}},
+			},
+		},
+		{
+			name:  "No clicks in range",
+			query: dto.StatsQuery{Bucket: dto.StatsBucketDay, From: base.Add(-48 * time.Hour), To: base.Add(-24 * time.Hour), Top: 10},
+			expected: dto.ClickStats{
+				Series:        []dto.SeriesPoint{},
+				TopReferrers:  []dto.ValueCount{},
+				TopUserAgents: []dto.ValueCount{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AggregateClicks(clicks, tt.query))
+		})
+	}
+}
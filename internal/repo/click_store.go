@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"sync"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// ClickStore keeps the click events of the memory and file backends, grouped
+// by short URL in the order they were added.
+type ClickStore struct {
+	mu     sync.RWMutex
+	clicks map[string][]dto.ClickEvent
+}
+
+func NewClickStore() *ClickStore {
+	return &ClickStore{clicks: make(map[string][]dto.ClickEvent)}
+}
+
+func (s *ClickStore) Add(clicks ...dto.ClickEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, click := range clicks {
+		s.clicks[click.ShortURL] = append(s.clicks[click.ShortURL], click)
+	}
+}
+
+// Clicks returns a copy of the click events of shortID.
+func (s *ClickStore) Clicks(shortID string) []dto.ClickEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clicks := make([]dto.ClickEvent, len(s.clicks[shortID]))
+	copy(clicks, s.clicks[shortID])
+	return clicks
+}
+
+// Len returns the number of click events of every short URL.
+func (s *ClickStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, clicks := range s.clicks {
+		n += len(clicks)
+	}
+	return n
+}
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrURLDeleted is returned by GetOriginalURL when the short URL exists but
+// has been soft-deleted by its owner.
+var ErrURLDeleted = errors.New("url has been deleted")
+
+// ErrURLExpired is returned by GetOriginalURL when the short URL exists but
+// its expiry time has passed, whether or not it has been tombstoned yet.
+var ErrURLExpired = errors.New("url has expired")
+
+// ErrShortIDTaken is returned by SaveShortID and SaveURLBatch when the short
+// URL is already stored, deleted or not.
+var ErrShortIDTaken = errors.New("short id is already taken")
+
+// ErrURLConflict is returned by SaveShortID when the original URL is already
+// stored. Use errors.As to get the existing short ID.
+type ErrURLConflict struct {
+	ShortID string
+}
+
+func (e *ErrURLConflict) Error() string {
+	return fmt.Sprintf("url is already shortened as %s", e.ShortID)
+}
+
+// BatchItemError reports the item that made a whole batch fail.
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,19 @@
+package repo
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// IsExpired reports whether record has an expiry time at or before now.
+func IsExpired(record dto.URLRecord, now time.Time) bool {
+	return !record.ExpiresAt.IsZero() && !record.ExpiresAt.After(now)
+}
+
+// NullTime maps the zero time to NULL, for the optional timestamp columns of
+// the SQL backends.
+func NullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
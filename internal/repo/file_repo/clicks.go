@@ -0,0 +1,95 @@
+package filerepo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/rs/zerolog/log"
+)
+
+// clicksPath is the JSON-lines log of click events, kept next to the log of
+// records. Click events are never superseded, so it is not compacted.
+func (r *FileRepository) clicksPath() string {
+	return r.cfg.FileStoragePath + ".clicks"
+}
+
+func (r *FileRepository) initClicksFile() {
+	file, err := os.OpenFile(r.clicksPath(), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open clicks file")
+		return
+	}
+	r.clicksFile = file
+
+	if err := r.loadClicks(); err != nil {
+		log.Error().Err(err).Str("path", r.clicksPath()).Msg("Failed to load clicks file")
+	}
+	log.Info().Int("clicks", r.clicks.Len()).Str("path", r.clicksPath()).Msg("Loaded clicks file")
+}
+
+// loadClicks replays the clicks log. Undecodable lines, such as a torn last
+// write, are skipped.
+func (r *FileRepository) loadClicks() error {
+	reader := bufio.NewReader(r.clicksFile)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read clicks file: %w", err)
+		}
+		if len(line) == 0 {
+			return nil
+		}
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var click dto.ClickEvent
+			if err := json.Unmarshal(trimmed, &click); err != nil {
+				log.Warn().Err(err).Int("line", lineNum).Msg("Skipping undecodable click")
+			} else {
+				r.clicks.Add(click)
+			}
+		}
+
+		if line[len(line)-1] != '\n' {
+			// Terminate the last line so that the next append starts on a new line.
+			if _, err := r.clicksFile.Write([]byte("\n")); err != nil {
+				return fmt.Errorf("failed to terminate last click: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// SaveClicks appends the click events to the clicks log in a single write.
+func (r *FileRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
+	if r.clicksFile == nil {
+		return fmt.Errorf("clicks file not initialized")
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, click := range clicks {
+		if err := encoder.Encode(click); err != nil {
+			return err
+		}
+	}
+
+	r.clicksMu.Lock()
+	defer r.clicksMu.Unlock()
+
+	if _, err := r.clicksFile.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write clicks: %w", err)
+	}
+	r.clicks.Add(clicks...)
+	return nil
+}
+
+func (r *FileRepository) GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error) {
+	return repo.AggregateClicks(r.clicks.Clicks(shortID), query), nil
+}
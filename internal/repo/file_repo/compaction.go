@@ -0,0 +1,149 @@
+package filerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultCompactMinRecords   = 1000
+	defaultCompactGarbageRatio = 0.5
+)
+
+// Compact rewrites the log so that it only holds the latest record of every
+// short URL. The live set is written to a temporary file next to the log,
+// fsynced and atomically renamed over it. Lookups are served from memory and
+// are not blocked; appends wait until the new log is in place.
+func (r *FileRepository) Compact(ctx context.Context) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("file storage not initialized")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	records := make([]dto.URLRecord, 0, r.storage.Len())
+	r.storage.Range(func(record dto.URLRecord) bool {
+		records = append(records, record)
+		return true
+	})
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ShortURL < records[j].ShortURL
+	})
+
+	path := r.cfg.FileStoragePath
+	tmpPath, err := writeSnapshot(path, records)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace storage file: %w", err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		log.Warn().Err(err).Msg("Failed to sync storage directory after compaction")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen storage file: %w", err)
+	}
+	r.file.Close()
+	r.file = file
+
+	log.Info().
+		Int("before", r.logRecords).
+		Int("after", len(records)).
+		Str("path", path).
+		Msg("Compacted file storage")
+	r.logRecords = len(records)
+	return nil
+}
+
+// needsCompaction reports whether superseded records make up at least
+// compactGarbageRatio of a log holding compactMinRecords or more records.
+// The caller must hold writeMu.
+func (r *FileRepository) needsCompaction() bool {
+	if r.logRecords < r.compactMinRecords || r.logRecords == 0 {
+		return false
+	}
+	garbage := r.logRecords - r.storage.Len()
+	return float64(garbage)/float64(r.logRecords) >= r.compactGarbageRatio
+}
+
+// maybeCompact runs a compaction when the threshold is reached, unless one is
+// already in progress.
+func (r *FileRepository) maybeCompact() {
+	if !r.compacting.CompareAndSwap(false, true) {
+		return
+	}
+	defer r.compacting.Store(false)
+
+	r.writeMu.Lock()
+	needed := r.needsCompaction()
+	r.writeMu.Unlock()
+	if !needed {
+		return
+	}
+
+	if err := r.Compact(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Failed to compact file storage")
+	}
+}
+
+// writeSnapshot writes records to a temp file next to path, with the mode of
+// path so that the rename keeps the permissions, and returns its name.
+func writeSnapshot(path string, records []dto.URLRecord) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat storage file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create compaction file: %w", err)
+	}
+	// CreateTemp always uses 0600.
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to set compaction file mode: %w", err)
+	}
+
+	encoder := json.NewEncoder(tmp)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return "", fmt.Errorf("failed to write compaction file: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to sync compaction file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close compaction file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
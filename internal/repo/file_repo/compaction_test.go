@@ -0,0 +1,126 @@
+package filerepo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countLines(t *testing.T, path string) int {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(content), "\n")
+}
+
+func TestCompact(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, fileRepo.SaveShortID(ctx, "u1", fmt.Sprintf("id%d", i), fmt.Sprintf("https://example.com/%d", i), time.Time{}))
+	}
+	require.NoError(t, fileRepo.BatchDeleteURLs(ctx, "u1", []string{"id0", "id1", "id2"}))
+	assert.Equal(t, 13, countLines(t, path))
+	require.NoError(t, os.Chmod(path, 0640))
+
+	require.NoError(t, fileRepo.Compact(ctx))
+	assert.Equal(t, 10, countLines(t, path))
+	assert.Equal(t, 10, fileRepo.logRecords)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), "the file keeps its mode")
+
+	// The temp file must have been renamed away.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{filepath.Base(path), filepath.Base(path) + ".clicks"}, names)
+
+	// Appends after compaction go to the new file.
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "new", "https://example.com/new", time.Time{}))
+	assert.Equal(t, 11, countLines(t, path))
+
+	reloaded := openTestFileRepository(t, path)
+	assert.Equal(t, 11, reloaded.storage.Len())
+	_, _, _, err = reloaded.GetOriginalURL(ctx, "id0")
+	assert.ErrorIs(t, err, repo.ErrURLDeleted)
+	originalURL, _, exists, err := reloaded.GetOriginalURL(ctx, "new")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com/new", originalURL)
+}
+
+func TestNeedsCompaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		logRecords int
+		live       int
+		expected   bool
+	}{
+		{name: "Below minimum size", logRecords: 10, live: 1, expected: false},
+		{name: "Mostly live records", logRecords: 2000, live: 1500, expected: false},
+		{name: "Garbage ratio reached", logRecords: 2000, live: 1000, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileRepo, _ := setupTestFileRepository(t, "")
+			for i := 0; i < tt.live; i++ {
+				fileRepo.storage.Set(dto.URLRecord{ShortURL: fmt.Sprintf("id%d", i)})
+			}
+			fileRepo.logRecords = tt.logRecords
+			assert.Equal(t, tt.expected, fileRepo.needsCompaction())
+		})
+	}
+}
+
+func TestAutomaticCompaction(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	fileRepo.compactMinRecords = 20
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, fileRepo.SaveShortID(ctx, "u1", fmt.Sprintf("id%d", i), fmt.Sprintf("https://example.com/%d", i), time.Time{}))
+	}
+	ids := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		ids = append(ids, fmt.Sprintf("id%d", i))
+	}
+	require.NoError(t, fileRepo.BatchDeleteURLs(ctx, "u1", ids))
+
+	assert.Eventually(t, func() bool {
+		fileRepo.writeMu.Lock()
+		defer fileRepo.writeMu.Unlock()
+		return fileRepo.logRecords == 10
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 10, countLines(t, path))
+}
+
+func TestCompactOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	var content strings.Builder
+	for i := 0; i < defaultCompactMinRecords; i++ {
+		fmt.Fprintf(&content, `{"uuid":"%d","short_url":"aaa","original_url":"https://example.com/%d"}`+"\n", i, i)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content.String()), 0644))
+
+	fileRepo := openTestFileRepository(t, path)
+	assert.Equal(t, 1, countLines(t, path))
+	originalURL, _, exists, err := fileRepo.GetOriginalURL(context.Background(), "aaa")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, fmt.Sprintf("https://example.com/%d", defaultCompactMinRecords-1), originalURL)
+}
@@ -0,0 +1,62 @@
+package filerepo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepositoryConcurrentAccess(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	// Keep compaction running alongside the writers.
+	fileRepo.compactMinRecords = 100
+	ctx := context.Background()
+
+	const (
+		workers = 8
+		perUser = 200
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", w)
+			for i := 0; i < perUser; i++ {
+				shortID := fmt.Sprintf("%d-%d", w, i)
+				assert.NoError(t, fileRepo.SaveShortID(ctx, userID, shortID, "https://example.com/"+shortID, time.Time{}))
+				_, _, _, err := fileRepo.GetOriginalURL(ctx, shortID)
+				assert.NoError(t, err)
+				if i%2 == 0 {
+					assert.NoError(t, fileRepo.BatchDeleteURLs(ctx, userID, []string{shortID}))
+				}
+				_, _, _, _ = fileRepo.GetOriginalURL(ctx, fmt.Sprintf("%d-%d", (w+1)%workers, i))
+				_, err = fileRepo.GetUserURLs(ctx, userID, "", 10)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Let a compaction that may still be running finish, then check that the
+	// log replays to the same state.
+	assert.Eventually(t, func() bool { return !fileRepo.compacting.Load() }, time.Second, 10*time.Millisecond)
+
+	reloaded := openTestFileRepository(t, path)
+	assert.Equal(t, workers*perUser, reloaded.storage.Len())
+	for w := 0; w < workers; w++ {
+		records, err := reloaded.GetUserURLs(ctx, fmt.Sprintf("user-%d", w), "", -1)
+		require.NoError(t, err)
+		assert.Len(t, records, perUser/2)
+
+		_, _, _, err = reloaded.GetOriginalURL(ctx, fmt.Sprintf("%d-0", w))
+		assert.ErrorIs(t, err, repo.ErrURLDeleted)
+	}
+}
@@ -1,10 +1,17 @@
 package filerepo
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/VladimirAzanza/url-shortener/config"
 	"github.com/VladimirAzanza/url-shortener/internal/dto"
@@ -16,53 +23,187 @@ import (
 type FileRepository struct {
 	cfg     *config.Config
 	file    *os.File
-	encoder *json.Encoder
-	storage map[string]string
+	storage *repo.RecordStore
+
+	// writeMu serializes appends to the log, storage updates that depend on
+	// the current record, and log compaction. Lookups only take the shard
+	// locks of storage.
+	writeMu sync.Mutex
+	// logRecords counts the records in the log, live or superseded.
+	logRecords          int
+	compacting          atomic.Bool
+	compactMinRecords   int
+	compactGarbageRatio float64
+
+	clicksFile *os.File
+	clicks     *repo.ClickStore
+	// clicksMu serializes appends to the clicks log.
+	clicksMu sync.Mutex
 }
 
 func NewFileRepository(cfg *config.Config) repo.IURLRepository {
 	fileRepo := &FileRepository{
-		cfg:     cfg,
-		storage: make(map[string]string, 0),
+		cfg:                 cfg,
+		storage:             repo.NewRecordStore(),
+		clicks:              repo.NewClickStore(),
+		compactMinRecords:   defaultCompactMinRecords,
+		compactGarbageRatio: defaultCompactGarbageRatio,
 	}
 	fileRepo.initFile()
+	fileRepo.initClicksFile()
 	return fileRepo
 }
 
 func (r *FileRepository) initFile() {
-	file, err := os.OpenFile(r.cfg.FileStoragePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(r.cfg.FileStoragePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to open storage file")
 		return
 	}
 	r.file = file
-	r.encoder = json.NewEncoder(file)
+
+	if err := r.loadRecords(); err != nil {
+		log.Error().Err(err).Str("path", r.cfg.FileStoragePath).Msg("Failed to load some records from storage file")
+	}
+	log.Info().Int("records", r.storage.Len()).Str("path", r.cfg.FileStoragePath).Msg("Loaded file storage")
+
+	r.maybeCompact()
+}
+
+// loadRecords replays the JSON-lines log into storage; later records (such as
+// tombstones) replace earlier ones with the same short URL. An undecodable last
+// line without a trailing newline is a torn write and is cut off the file.
+// Any other undecodable line is skipped and reported with its line number.
+func (r *FileRepository) loadRecords() error {
+	reader := bufio.NewReader(r.file)
+	var (
+		offset int64
+		errs   []error
+	)
+
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read storage file: %w", err)
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		complete := line[len(line)-1] == '\n'
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			record, decodeErr := decodeRecord(trimmed)
+			switch {
+			case decodeErr != nil && !complete:
+				log.Warn().Err(decodeErr).Int("line", lineNum).Msg("Truncating incomplete last record")
+				if err := r.file.Truncate(offset); err != nil {
+					return fmt.Errorf("failed to truncate incomplete record at line %d: %w", lineNum, err)
+				}
+				return errors.Join(errs...)
+			case decodeErr != nil:
+				errs = append(errs, fmt.Errorf("line %d: %w", lineNum, decodeErr))
+			default:
+				r.storage.Set(record)
+				r.logRecords++
+			}
+		}
+
+		offset += int64(len(line))
+		if !complete {
+			// Terminate the last record so that the next append starts on a new line.
+			if _, err := r.file.Write([]byte("\n")); err != nil {
+				return fmt.Errorf("failed to terminate last record: %w", err)
+			}
+			break
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func (r *FileRepository) SaveShortID(ctx context.Context, shortID, originalURL string) error {
+func decodeRecord(line []byte) (dto.URLRecord, error) {
+	var record dto.URLRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return record, err
+	}
+	if record.ShortURL == "" {
+		return record, errors.New("record has no short_url")
+	}
+	return record, nil
+}
+
+func (r *FileRepository) SaveShortID(
+	ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time,
+) error {
+	now := time.Now().UTC()
 	urlRecord := dto.URLRecord{
 		UUID:        uuid.New().String(),
 		ShortURL:    shortID,
 		OriginalURL: originalURL,
+		UserID:      userID,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
 	}
-	r.storage[shortID] = originalURL
 
-	if err := r.encoder.Encode(urlRecord); err != nil {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, exists := r.storage.Get(shortID); exists {
+		return repo.ErrShortIDTaken
+	}
+	if existingShortID, exists := r.storage.ShortIDByOriginalURL(originalURL, now); exists {
+		return &repo.ErrURLConflict{ShortID: existingShortID}
+	}
+	if err := r.appendRecord(urlRecord); err != nil {
 		return fmt.Errorf("failed to write record: %w", err)
 	}
+	r.storage.Set(urlRecord)
 	return nil
 }
 
-func (r *FileRepository) SaveBatchURL(ctx context.Context, shortID, originalURL string) error {
-	return r.SaveShortID(ctx, shortID, originalURL)
+// SaveURLBatch appends the new records of the batch to the log in a single
+// write, so that either all of them are stored or none is.
+func (r *FileRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	now := time.Now().UTC()
+	records = repo.WithCreatedAt(records, now)
+	for i := range records {
+		records[i].UUID = uuid.New().String()
+	}
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	saved, inserts, err := repo.PlanBatch(records,
+		func(shortID string) bool {
+			_, exists := r.storage.Get(shortID)
+			return exists
+		},
+		func(originalURL string) (string, bool) {
+			return r.storage.ShortIDByOriginalURL(originalURL, now)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.appendRecords(inserts...); err != nil {
+		return nil, fmt.Errorf("failed to write records: %w", err)
+	}
+	for _, record := range inserts {
+		r.storage.Set(record)
+	}
+	return saved, nil
 }
 
-// Implementation for searching in the file (may be inefficient for many records)
-// In a real implementation, consider using a database or an index.
-func (r *FileRepository) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
-	originalURL, ok := r.storage[shortID]
-	log.Info().Msg("In a real implementation, consider using a database or an index.")
-	return originalURL, ok, nil
+func (r *FileRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+	record, ok := r.storage.Get(shortID)
+	if ok && repo.IsExpired(record, time.Now()) {
+		return "", time.Time{}, false, repo.ErrURLExpired
+	}
+	if ok && record.IsDeleted {
+		return "", time.Time{}, false, repo.ErrURLDeleted
+	}
+	return record.OriginalURL, record.ExpiresAt, ok, nil
 }
 
 func (r *FileRepository) Ping(ctx context.Context) error {
@@ -73,14 +214,111 @@ func (r *FileRepository) Ping(ctx context.Context) error {
 }
 
 func (r *FileRepository) GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
-	for shortID, url := range r.storage {
-		if url == originalURL {
-			return shortID, nil
+	shortID, _ := r.storage.ShortIDByOriginalURL(originalURL, time.Now())
+	return shortID, nil
+}
+
+func (r *FileRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	record, ok := r.storage.Get(shortID)
+	return record.UserID, ok, nil
+}
+
+func (r *FileRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	records := make([]dto.URLRecord, 0)
+	r.storage.Range(func(record dto.URLRecord) bool {
+		if record.UserID == userID && !record.IsDeleted && record.ShortURL > cursor {
+			records = append(records, record)
+		}
+		return true
+	})
+	return repo.PageURLRecords(records, limit), nil
+}
+
+func (r *FileRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	return r.storage.Page(cursor, limit), nil
+}
+
+// BatchDeleteURLs marks the user's records as deleted and appends a tombstone
+// (the same record with is_deleted set) for each of them to the storage file.
+func (r *FileRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	for _, shortID := range shortURLs {
+		record, ok := r.storage.Get(shortID)
+		if !ok || record.UserID != userID || record.IsDeleted {
+			continue
+		}
+		record.IsDeleted = true
+		if err := r.appendRecord(record); err != nil {
+			return fmt.Errorf("failed to write tombstone: %w", err)
 		}
+		r.storage.Set(record)
+	}
+	return nil
+}
+
+// ExpireURLs appends a tombstone for each expired record in a single write.
+func (r *FileRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	var tombstones []dto.URLRecord
+	for _, shortID := range r.storage.Expired(now, limit) {
+		record, ok := r.storage.Get(shortID)
+		if !ok || record.IsDeleted {
+			continue
+		}
+		record.IsDeleted = true
+		tombstones = append(tombstones, record)
+	}
+
+	if err := r.appendRecords(tombstones...); err != nil {
+		return 0, fmt.Errorf("failed to write tombstones: %w", err)
 	}
-	return "", nil
+	for _, record := range tombstones {
+		r.storage.Set(record)
+	}
+	return len(tombstones), nil
+}
+
+func (r *FileRepository) appendRecord(record dto.URLRecord) error {
+	return r.appendRecords(record)
 }
 
-func (r *FileRepository) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+// appendRecords writes records to the end of the log in a single write and
+// schedules a compaction when the log has accumulated enough superseded
+// records. A failed write is cut off the log. The caller must hold writeMu.
+func (r *FileRepository) appendRecords(records ...dto.URLRecord) error {
+	if r.file == nil {
+		return fmt.Errorf("file storage not initialized")
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	info, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := r.file.Write(buf.Bytes()); err != nil {
+		if truncErr := r.file.Truncate(info.Size()); truncErr != nil {
+			return errors.Join(err, truncErr)
+		}
+		return err
+	}
+	r.logRecords += len(records)
+
+	if r.needsCompaction() {
+		go r.maybeCompact()
+	}
 	return nil
 }
@@ -0,0 +1,343 @@
+package filerepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestFileRepository(t *testing.T, content string) (*FileRepository, string) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	if content != "" {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return openTestFileRepository(t, path), path
+}
+
+func openTestFileRepository(t *testing.T, path string) *FileRepository {
+	fileRepo := NewFileRepository(&config.Config{FileStoragePath: path}).(*FileRepository)
+	t.Cleanup(func() { fileRepo.file.Close() })
+	return fileRepo
+}
+
+func TestLoadRecords(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		expectedURLs    map[string]string
+		expectedDeleted []string
+		expectedFile    string
+		expectedErrLine string
+	}{
+		{
+			name: "Replays records and tombstones",
+			content: `{"uuid":"1","short_url":"aaa","original_url":"www.example2.com"}
+{"uuid":"2","short_url":"bbb","original_url":"http://httpbin.org/delay/2","user_id":"u1"}
+{"uuid":"2","short_url":"bbb","original_url":"http://httpbin.org/delay/2","user_id":"u1","is_deleted":true}
+`,
+			expectedURLs:    map[string]string{"aaa": "www.example2.com"},
+			expectedDeleted: []string{"bbb"},
+		},
+		{
+			name: "Truncated last line is cut off",
+			content: `{"uuid":"1","short_url":"aaa","original_url":"https://example.com"}
+{"uuid":"2","short_url":"bbb","orig`,
+			expectedURLs: map[string]string{"aaa": "https://example.com"},
+			expectedFile: `{"uuid":"1","short_url":"aaa","original_url":"https://example.com"}
+`,
+		},
+		{
+			name: "Valid last line without newline is kept",
+			content: `{"uuid":"1","short_url":"aaa","original_url":"https://example.com"}
+{"uuid":"2","short_url":"bbb","original_url":"https://example.org"}`,
+			expectedURLs: map[string]string{"aaa": "https://example.com", "bbb": "https://example.org"},
+		},
+		{
+			name: "Corrupt lines are skipped",
+			content: `{"uuid":"1","short_url":"aaa","original_url":"https://example.com"}
+not json
+
+{"uuid":"3","original_url":"https://example.net"}
+{"uuid":"4","short_url":"ddd","original_url":"https://example.org"}
+`,
+			expectedURLs: map[string]string{"aaa": "https://example.com", "ddd": "https://example.org"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileRepo, path := setupTestFileRepository(t, tt.content)
+			ctx := context.Background()
+
+			for shortID, expectedURL := range tt.expectedURLs {
+				originalURL, _, exists, err := fileRepo.GetOriginalURL(ctx, shortID)
+				assert.NoError(t, err)
+				assert.True(t, exists)
+				assert.Equal(t, expectedURL, originalURL)
+			}
+			for _, shortID := range tt.expectedDeleted {
+				_, _, _, err := fileRepo.GetOriginalURL(ctx, shortID)
+				assert.ErrorIs(t, err, repo.ErrURLDeleted)
+			}
+
+			if tt.expectedFile != "" {
+				content, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedFile, string(content))
+			}
+
+			// New records must be appended on their own line and survive a restart.
+			require.NoError(t, fileRepo.SaveShortID(ctx, "u2", "zzz", "https://example.com/new", time.Time{}))
+			reloaded := openTestFileRepository(t, path)
+			assert.Equal(t, len(tt.expectedURLs)+len(tt.expectedDeleted)+1, reloaded.storage.Len())
+			originalURL, _, exists, err := reloaded.GetOriginalURL(ctx, "zzz")
+			assert.NoError(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, "https://example.com/new", originalURL)
+			shortID, err := reloaded.GetShortIDByOriginalURL(ctx, "https://example.com/new")
+			assert.NoError(t, err)
+			assert.Equal(t, "zzz", shortID)
+		})
+	}
+}
+
+func TestLoadRecordsReportsLineNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	content := `{"uuid":"1","short_url":"aaa","original_url":"https://example.com"}
+not json
+{"uuid":"3","original_url":"https://example.net"}
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	defer file.Close()
+
+	fileRepo := &FileRepository{file: file, storage: repo.NewRecordStore()}
+	err = fileRepo.loadRecords()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+	assert.Contains(t, err.Error(), "line 3")
+	assert.Equal(t, 1, fileRepo.storage.Len())
+}
+
+func TestSaveShortIDConflicts(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "spring-sale", "https://example.com", time.Time{}))
+	err := fileRepo.SaveShortID(ctx, "u2", "spring-sale", "https://example.org", time.Time{})
+	assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+
+	err = fileRepo.SaveShortID(ctx, "u2", "other", "https://example.com", time.Time{})
+	var conflict *repo.ErrURLConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "spring-sale", conflict.ShortID)
+
+	reloaded := openTestFileRepository(t, path)
+	originalURL, _, exists, err := reloaded.GetOriginalURL(ctx, "spring-sale")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestSaveURLBatch(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "spring-sale", "https://example.com", time.Time{}))
+
+	saved, err := fileRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://example.org", UserID: "u2"},
+		{ShortURL: "def", OriginalURL: "https://example.com", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", saved[0].ShortURL)
+	assert.Equal(t, "spring-sale", saved[1].ShortURL)
+	assert.Equal(t, 2, countLines(t, path))
+
+	_, err = fileRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "ghi", OriginalURL: "https://example.net", UserID: "u2"},
+		{ShortURL: "abc", OriginalURL: "https://example.io", UserID: "u2"},
+	})
+	assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+	assert.Equal(t, 2, countLines(t, path), "a failed batch writes nothing")
+
+	reloaded := openTestFileRepository(t, path)
+	originalURL, _, exists, err := reloaded.GetOriginalURL(ctx, "abc")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.org", originalURL)
+	_, _, exists, _ = reloaded.GetOriginalURL(ctx, "ghi")
+	assert.False(t, exists)
+
+	// The hash strategy gives a URL repeated in a batch the same short ID.
+	saved, err = fileRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "jkl", OriginalURL: "https://example.net", UserID: "u2"},
+		{ShortURL: "jkl", OriginalURL: "https://example.net", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "jkl", saved[0].ShortURL)
+	assert.Equal(t, "jkl", saved[1].ShortURL)
+	assert.Equal(t, 3, countLines(t, path))
+}
+
+func TestExpireURLs(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "old", "https://example.com/old", now.Add(-2*time.Hour)))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "older", "https://example.com/older", now.Add(-3*time.Hour)))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "later", "https://example.com/later", now.Add(time.Hour)))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "never", "https://example.com/never", time.Time{}))
+
+	_, _, exists, err := fileRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired, "expired links are gone before the janitor runs")
+	assert.False(t, exists)
+
+	expired, err := fileRepo.ExpireURLs(ctx, now, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = fileRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = fileRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	_, _, _, err = fileRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired)
+	originalURL, _, exists, err := fileRepo.GetOriginalURL(ctx, "later")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com/later", originalURL)
+
+	records, err := fileRepo.GetUserURLs(ctx, "u1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "later", records[0].ShortURL)
+	assert.Equal(t, "never", records[1].ShortURL)
+
+	// The tombstones and expiry times survive a restart.
+	reopened := openTestFileRepository(t, path)
+	records, err = reopened.ListURLs(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, "later", records[0].ShortURL)
+	assert.False(t, records[0].IsDeleted)
+	assert.Equal(t, "old", records[2].ShortURL)
+	assert.True(t, records[2].IsDeleted)
+	assert.True(t, now.Add(-2*time.Hour).Equal(records[2].ExpiresAt))
+}
+
+func TestSaveClicks(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+	clickedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	clicks := []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: clickedAt, Referrer: "https://news.example.com/", IPHash: "h1"},
+		{ShortURL: "b", ClickedAt: clickedAt, IPHash: "h1"},
+	}
+	require.NoError(t, fileRepo.SaveClicks(ctx, clicks))
+	require.NoError(t, fileRepo.SaveClicks(ctx, []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: clickedAt.Add(time.Minute), UserAgent: "curl/8.0", IPHash: "h2"},
+	}))
+	assert.Len(t, fileRepo.clicks.Clicks("a"), 2)
+
+	// A torn last line is skipped and terminated on load.
+	clicksFile, err := os.OpenFile(path+".clicks", os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = clicksFile.WriteString(`{"short_url":"a","click`)
+	require.NoError(t, err)
+	require.NoError(t, clicksFile.Close())
+
+	reloaded := openTestFileRepository(t, path)
+	assert.Equal(t, 3, reloaded.clicks.Len())
+	assert.Equal(t, clicks[:1], reloaded.clicks.Clicks("a")[:1])
+
+	require.NoError(t, reloaded.SaveClicks(ctx, []dto.ClickEvent{{ShortURL: "b", ClickedAt: clickedAt, IPHash: "h3"}}))
+	assert.Equal(t, 4, openTestFileRepository(t, path).clicks.Len())
+}
+
+func TestSaveDeadURLAgain(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "deleted", "https://example.com/deleted", time.Time{}))
+	require.NoError(t, fileRepo.BatchDeleteURLs(ctx, "u1", []string{"deleted"}))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "expired", "https://example.com/expired", time.Now().Add(-time.Hour)))
+
+	for _, originalURL := range []string{"https://example.com/deleted", "https://example.com/expired"} {
+		shortID, err := fileRepo.GetShortIDByOriginalURL(ctx, originalURL)
+		require.NoError(t, err)
+		assert.Empty(t, shortID, "%s has no live link", originalURL)
+	}
+
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u2", "new-deleted", "https://example.com/deleted", time.Time{}))
+	saved, err := fileRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "new-expired", OriginalURL: "https://example.com/expired", UserID: "u2"},
+		{ShortURL: "dup", OriginalURL: "https://example.com/deleted", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new-expired", saved[0].ShortURL)
+	assert.Equal(t, "new-deleted", saved[1].ShortURL)
+
+	// Replaying the log, tombstones included, indexes the new links only.
+	reloaded := openTestFileRepository(t, path)
+	for originalURL, expected := range map[string]string{
+		"https://example.com/deleted": "new-deleted",
+		"https://example.com/expired": "new-expired",
+	} {
+		shortID, err := reloaded.GetShortIDByOriginalURL(ctx, originalURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, shortID)
+	}
+}
+
+func TestBatchDeleteURLs(t *testing.T) {
+	fileRepo, path := setupTestFileRepository(t, "")
+	ctx := context.Background()
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "a", "https://example.com/a", time.Time{}))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u1", "b", "https://example.com/b", time.Time{}))
+	require.NoError(t, fileRepo.SaveShortID(ctx, "u2", "c", "https://example.com/c", time.Time{}))
+
+	// c belongs to another user and missing does not exist: both are left alone.
+	require.NoError(t, fileRepo.BatchDeleteURLs(ctx, "u1", []string{"a", "c", "missing"}))
+	// Deleting again appends no second tombstone.
+	require.NoError(t, fileRepo.BatchDeleteURLs(ctx, "u1", []string{"a"}))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(content), "\n"), "three records and one tombstone")
+
+	// The tombstone is replayed on load.
+	for name, r := range map[string]*FileRepository{"live": fileRepo, "reloaded": openTestFileRepository(t, path)} {
+		t.Run(name, func(t *testing.T) {
+			_, _, exists, err := r.GetOriginalURL(ctx, "a")
+			assert.ErrorIs(t, err, repo.ErrURLDeleted)
+			assert.False(t, exists)
+			for _, shortID := range []string{"b", "c"} {
+				originalURL, _, exists, err := r.GetOriginalURL(ctx, shortID)
+				require.NoError(t, err)
+				assert.True(t, exists)
+				assert.Equal(t, "https://example.com/"+shortID, originalURL)
+			}
+
+			records, err := r.GetUserURLs(ctx, "u1", "", 10)
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.Equal(t, "b", records[0].ShortURL)
+		})
+	}
+}
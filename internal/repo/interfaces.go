@@ -1,6 +1,11 @@
 package repo
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
 
 // type ISQLiteStorage interface {
 // 	SaveBatchURL(ctx context.Context, shortID, originalURL string) error
@@ -16,11 +21,40 @@ import "context"
 // }
 
 type IURLRepository interface {
-	SaveShortID(ctx context.Context, shortID, originalURL string) error
-	SaveBatchURL(ctx context.Context, shortID, originalURL string) error
-	GetOriginalURL(ctx context.Context, shortID string) (string, bool, error)
+	// SaveShortID stores a new link, which expires at expiresAt unless it is
+	// zero. Only live links count as storing an original URL: deleted and
+	// expired ones do not keep it from being shortened again.
+	SaveShortID(ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time) error
+	// SaveURLBatch stores records all-or-nothing, deleted flag, creation and
+	// expiry times included, and returns them in the same order. A record whose
+	// original URL already has a live link, or appears earlier in the batch,
+	// comes back with the existing short URL instead. A taken short URL fails
+	// the whole batch with a *BatchItemError wrapping ErrShortIDTaken.
+	SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error)
+	// GetOriginalURL resolves a short URL, along with the time it expires at,
+	// which is zero for links that never expire. Expired links are reported
+	// with ErrURLExpired and deleted ones with ErrURLDeleted.
+	GetOriginalURL(ctx context.Context, shortID string) (originalURL string, expiresAt time.Time, exists bool, err error)
+	// GetShortIDByOriginalURL returns the live link of originalURL, or an
+	// empty string when it has none.
 	GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error)
-	BatchDeleteURLs(ctx context.Context, shortURLs []string) error
+	// GetURLOwner returns the user who stored a short URL, deleted and expired
+	// links included. Links stored before ownership have no owner.
+	GetURLOwner(ctx context.Context, shortID string) (string, bool, error)
+	GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error)
+	// ListURLs returns up to limit records of every user, deleted ones
+	// included, ordered by short URL and starting after cursor.
+	ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error)
+	BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error
+	// ExpireURLs marks as deleted up to limit links that expired at or before
+	// now and are not deleted yet, and returns how many it marked.
+	ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error)
+	// SaveClicks stores a batch of click events.
+	SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error
+	// GetClickStats aggregates the clicks of a short URL in [query.From,
+	// query.To). The series only holds the non-empty UTC buckets, in order;
+	// the top lists are sorted by clicks then value and leave empty values out.
+	GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error)
 	Ping(ctx context.Context) error
 }
 
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// URLIterator walks every record of a repository in short URL order, one
+// ListURLs page at a time, so that only a page is held in memory:
+//
+//	it := repo.NewURLIterator(r, 1000)
+//	for it.Next(ctx) {
+//		record := it.Record()
+//	}
+//	if err := it.Err(); err != nil {
+//
+// Records stored while iterating are seen only if they sort after the
+// current page.
+type URLIterator struct {
+	repo     IURLRepository
+	pageSize int
+	cursor   string
+	page     []dto.URLRecord
+	pos      int
+	done     bool
+	err      error
+}
+
+func NewURLIterator(repo IURLRepository, pageSize int) *URLIterator {
+	return &URLIterator{
+		repo:     repo,
+		pageSize: pageSize,
+		pos:      -1,
+	}
+}
+
+// NewURLIteratorAfter starts the iteration after cursor, such as the short URL
+// of the last record seen by an earlier iteration.
+func NewURLIteratorAfter(repo IURLRepository, pageSize int, cursor string) *URLIterator {
+	it := NewURLIterator(repo, pageSize)
+	it.cursor = cursor
+	return it
+}
+
+// Next advances to the next record, fetching the next page when needed. It
+// returns false at the end of the records or on error.
+func (it *URLIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.pos+1 < len(it.page) {
+		it.pos++
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	page, err := it.repo.ListURLs(ctx, it.cursor, it.pageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	// A short page is the last one: skip the query that would return nothing.
+	it.done = len(page) < it.pageSize
+	if len(page) == 0 {
+		return false
+	}
+
+	it.page = page
+	it.pos = 0
+	it.cursor = page[len(page)-1].ShortURL
+	return true
+}
+
+// Record returns the current record. It is only valid after Next returned
+// true.
+func (it *URLIterator) Record() dto.URLRecord {
+	return it.page[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *URLIterator) Err() error {
+	return it.err
+}
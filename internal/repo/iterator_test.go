@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedRepository serves ListURLs from a store and counts the calls.
+type pagedRepository struct {
+	IURLRepository
+	store   *RecordStore
+	calls   int
+	failAt  int
+	failErr error
+}
+
+func (r *pagedRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	r.calls++
+	if r.calls == r.failAt {
+		return nil, r.failErr
+	}
+	return r.store.Page(cursor, limit), nil
+}
+
+func newPagedRepository(n int) *pagedRepository {
+	store := NewRecordStore()
+	for i := 0; i < n; i++ {
+		store.Set(dto.URLRecord{ShortURL: fmt.Sprintf("id%03d", i), OriginalURL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	return &pagedRepository{store: store}
+}
+
+func TestURLIterator(t *testing.T) {
+	tests := []struct {
+		name          string
+		records       int
+		pageSize      int
+		expectedCalls int
+	}{
+		{name: "Empty", records: 0, pageSize: 10, expectedCalls: 1},
+		{name: "Short last page", records: 25, pageSize: 10, expectedCalls: 3},
+		{name: "Full last page", records: 30, pageSize: 10, expectedCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newPagedRepository(tt.records)
+			ctx := context.Background()
+
+			var shortIDs []string
+			it := NewURLIterator(r, tt.pageSize)
+			for it.Next(ctx) {
+				shortIDs = append(shortIDs, it.Record().ShortURL)
+			}
+			require.NoError(t, it.Err())
+
+			require.Len(t, shortIDs, tt.records)
+			for i, shortID := range shortIDs {
+				assert.Equal(t, fmt.Sprintf("id%03d", i), shortID)
+			}
+			assert.Equal(t, tt.expectedCalls, r.calls)
+			assert.False(t, it.Next(ctx), "a finished iterator stays finished")
+		})
+	}
+}
+
+func TestURLIteratorError(t *testing.T) {
+	r := newPagedRepository(25)
+	r.failAt, r.failErr = 2, errors.New("db error")
+	ctx := context.Background()
+
+	var n int
+	it := NewURLIterator(r, 10)
+	for it.Next(ctx) {
+		n++
+	}
+	assert.Equal(t, 10, n)
+	assert.ErrorIs(t, it.Err(), r.failErr)
+	assert.False(t, it.Next(ctx))
+}
@@ -2,33 +2,52 @@ package memory
 
 import (
 	"context"
+	"time"
 
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
 	"github.com/VladimirAzanza/url-shortener/internal/repo"
 	"github.com/rs/zerolog/log"
 )
 
 type MemoryRepository struct {
-	storage map[string]string
+	storage *repo.RecordStore
+	clicks  *repo.ClickStore
 }
 
 func NewMemoryRepository() repo.IURLRepository {
 	return &MemoryRepository{
-		storage: make(map[string]string, 0),
+		storage: repo.NewRecordStore(),
+		clicks:  repo.NewClickStore(),
 	}
 }
 
-func (r *MemoryRepository) SaveShortID(ctx context.Context, shortID, originalURL string) error {
-	r.storage[shortID] = originalURL
-	return nil
+func (r *MemoryRepository) SaveShortID(
+	ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time,
+) error {
+	now := time.Now().UTC()
+	return r.storage.Insert(dto.URLRecord{
+		ShortURL:    shortID,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+	}, now)
 }
 
-func (r *MemoryRepository) SaveBatchURL(ctx context.Context, shortID, originalURL string) error {
-	return r.SaveShortID(ctx, shortID, originalURL)
+func (r *MemoryRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	now := time.Now().UTC()
+	return r.storage.InsertBatch(repo.WithCreatedAt(records, now), now)
 }
 
-func (r *MemoryRepository) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
-	originalURL, ok := r.storage[shortID]
-	return originalURL, ok, nil
+func (r *MemoryRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+	record, ok := r.storage.Get(shortID)
+	if ok && repo.IsExpired(record, time.Now()) {
+		return "", time.Time{}, false, repo.ErrURLExpired
+	}
+	if ok && record.IsDeleted {
+		return "", time.Time{}, false, repo.ErrURLDeleted
+	}
+	return record.OriginalURL, record.ExpiresAt, ok, nil
 }
 
 func (r *MemoryRepository) Ping(ctx context.Context) error {
@@ -37,14 +56,63 @@ func (r *MemoryRepository) Ping(ctx context.Context) error {
 }
 
 func (r *MemoryRepository) GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
-	for shortID, url := range r.storage {
-		if url == originalURL {
-			return shortID, nil
+	shortID, _ := r.storage.ShortIDByOriginalURL(originalURL, time.Now())
+	return shortID, nil
+}
+
+func (r *MemoryRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	record, ok := r.storage.Get(shortID)
+	return record.UserID, ok, nil
+}
+
+func (r *MemoryRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	records := make([]dto.URLRecord, 0)
+	r.storage.Range(func(record dto.URLRecord) bool {
+		if record.UserID == userID && !record.IsDeleted && record.ShortURL > cursor {
+			records = append(records, record)
 		}
+		return true
+	})
+	return repo.PageURLRecords(records, limit), nil
+}
+
+func (r *MemoryRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	return r.storage.Page(cursor, limit), nil
+}
+
+func (r *MemoryRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	for _, shortID := range shortURLs {
+		r.storage.Update(shortID, func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+			if !exists || record.UserID != userID || record.IsDeleted {
+				return record, false
+			}
+			record.IsDeleted = true
+			return record, true
+		})
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	expired := 0
+	for _, shortID := range r.storage.Expired(now, limit) {
+		r.storage.Update(shortID, func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+			if !exists || record.IsDeleted || !repo.IsExpired(record, now) {
+				return record, false
+			}
+			record.IsDeleted = true
+			expired++
+			return record, true
+		})
 	}
-	return "", nil
+	return expired, nil
 }
 
-func (r *MemoryRepository) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+func (r *MemoryRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
+	r.clicks.Add(clicks...)
 	return nil
 }
+
+func (r *MemoryRepository) GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error) {
+	return repo.AggregateClicks(r.clicks.Clicks(shortID), query), nil
+}
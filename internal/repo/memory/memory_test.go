@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepositoryConcurrentAccess(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+
+	const (
+		workers = 16
+		perUser = 200
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", w)
+			for i := 0; i < perUser; i++ {
+				shortID := fmt.Sprintf("%d-%d", w, i)
+				assert.NoError(t, memoryRepo.SaveShortID(ctx, userID, shortID, "https://example.com/"+shortID, time.Time{}))
+				_, _, _, err := memoryRepo.GetOriginalURL(ctx, shortID)
+				assert.NoError(t, err)
+				if i%2 == 0 {
+					assert.NoError(t, memoryRepo.BatchDeleteURLs(ctx, userID, []string{shortID}))
+				}
+				// Readers of other users' data run alongside the writers.
+				_, _, _, _ = memoryRepo.GetOriginalURL(ctx, fmt.Sprintf("%d-%d", (w+1)%workers, i))
+				_, err = memoryRepo.GetUserURLs(ctx, userID, "", 10)
+				assert.NoError(t, err)
+				_, err = memoryRepo.GetShortIDByOriginalURL(ctx, "https://example.com/"+shortID)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		records, err := memoryRepo.GetUserURLs(ctx, fmt.Sprintf("user-%d", w), "", -1)
+		require.NoError(t, err)
+		assert.Len(t, records, perUser/2)
+
+		_, _, _, err = memoryRepo.GetOriginalURL(ctx, fmt.Sprintf("%d-0", w))
+		assert.ErrorIs(t, err, repo.ErrURLDeleted)
+	}
+}
+
+func TestMemoryRepositoryGetShortIDByOriginalURL(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "abc", "https://example.com", time.Time{}))
+
+	tests := []struct {
+		name        string
+		originalURL string
+		expected    string
+	}{
+		{name: "Known URL", originalURL: "https://example.com", expected: "abc"},
+		{name: "Unknown URL", originalURL: "https://example.org", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortID, err := memoryRepo.GetShortIDByOriginalURL(ctx, tt.originalURL)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, shortID)
+		})
+	}
+}
+
+func TestMemoryRepositorySaveConflicts(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "spring-sale", "https://example.com", time.Time{}))
+	err := memoryRepo.SaveShortID(ctx, "u2", "spring-sale", "https://example.org", time.Time{})
+	assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+
+	err = memoryRepo.SaveShortID(ctx, "u2", "other", "https://example.com", time.Time{})
+	var conflict *repo.ErrURLConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "spring-sale", conflict.ShortID)
+
+	originalURL, _, exists, err := memoryRepo.GetOriginalURL(ctx, "spring-sale")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestMemoryRepositorySaveURLBatch(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "a", "https://example.com/a", time.Time{}))
+
+	// The hash strategy gives a URL repeated in a batch the same short ID.
+	saved, err := memoryRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "b", OriginalURL: "https://example.com/b", UserID: "u2"},
+		{ShortURL: "b", OriginalURL: "https://example.com/b", UserID: "u2"},
+		{ShortURL: "c", OriginalURL: "https://example.com/a", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "b", "a"}, []string{saved[0].ShortURL, saved[1].ShortURL, saved[2].ShortURL})
+
+	_, err = memoryRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "d", OriginalURL: "https://example.com/d", UserID: "u2"},
+		{ShortURL: "d", OriginalURL: "https://example.com/e", UserID: "u2"},
+	})
+	var itemErr *repo.BatchItemError
+	if assert.ErrorAs(t, err, &itemErr) {
+		assert.Equal(t, 1, itemErr.Index)
+		assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+	}
+}
+
+func TestMemoryRepositoryListURLs(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	createdAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "b", "https://example.com/b", time.Time{}))
+	_, err := memoryRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "a", OriginalURL: "https://example.com/a", UserID: "u2", CreatedAt: createdAt},
+		{ShortURL: "c", OriginalURL: "https://example.com/c", UserID: "u1"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, memoryRepo.BatchDeleteURLs(ctx, "u1", []string{"c"}))
+
+	records, err := memoryRepo.ListURLs(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, createdAt, records[0].CreatedAt, "a copied creation time is kept")
+	assert.False(t, records[1].CreatedAt.IsZero())
+	assert.False(t, records[2].CreatedAt.IsZero())
+	assert.True(t, records[2].IsDeleted)
+
+	records, err = memoryRepo.ListURLs(ctx, "a", 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].ShortURL)
+}
+
+func TestMemoryRepositoryExpireURLs(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "old", "https://example.com/old", now.Add(-2*time.Hour)))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "older", "https://example.com/older", now.Add(-3*time.Hour)))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "later", "https://example.com/later", now.Add(time.Hour)))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "never", "https://example.com/never", time.Time{}))
+
+	_, _, exists, err := memoryRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired, "expired links are gone before the janitor runs")
+	assert.False(t, exists)
+
+	expired, err := memoryRepo.ExpireURLs(ctx, now, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = memoryRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = memoryRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	_, _, _, err = memoryRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired)
+	originalURL, _, exists, err := memoryRepo.GetOriginalURL(ctx, "later")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com/later", originalURL)
+
+	records, err := memoryRepo.GetUserURLs(ctx, "u1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "later", records[0].ShortURL)
+	assert.Equal(t, "never", records[1].ShortURL)
+}
+
+func TestMemoryRepositorySaveClicks(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	clickedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, memoryRepo.SaveClicks(ctx, []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: clickedAt, Referrer: "https://news.example.com/", IPHash: "h1"},
+		{ShortURL: "b", ClickedAt: clickedAt, IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: clickedAt.Add(time.Minute), UserAgent: "curl/8.0", IPHash: "h2"},
+	}))
+
+	clicks := memoryRepo.(*MemoryRepository).clicks.Clicks("a")
+	require.Len(t, clicks, 2)
+	assert.Equal(t, "https://news.example.com/", clicks[0].Referrer)
+	assert.Equal(t, "curl/8.0", clicks[1].UserAgent)
+}
+
+func TestMemoryRepositoryGetURLOwner(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "mine", "https://example.com", time.Time{}))
+	require.NoError(t, memoryRepo.BatchDeleteURLs(ctx, "u1", []string{"mine"}))
+
+	owner, exists, err := memoryRepo.GetURLOwner(ctx, "mine")
+	require.NoError(t, err)
+	assert.True(t, exists, "deleted links keep their owner")
+	assert.Equal(t, "u1", owner)
+
+	_, exists, err = memoryRepo.GetURLOwner(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMemoryRepositoryGetClickStats(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, memoryRepo.SaveClicks(ctx, []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: base, Referrer: "https://r1.example/", IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: base.Add(time.Minute), IPHash: "h1"},
+		{ShortURL: "b", ClickedAt: base, IPHash: "h2"},
+	}))
+
+	stats, err := memoryRepo.GetClickStats(ctx, "a", dto.StatsQuery{
+		Bucket: dto.StatsBucketHour, From: base, To: base.Add(time.Hour), Top: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Equal(t, int64(1), stats.UniqueVisitors)
+	assert.Equal(t, []dto.SeriesPoint{{Start: base, Clicks: 2}}, stats.Series)
+	assert.Equal(t, []dto.ValueCount{{Value: "https://r1.example/", Clicks: 1}}, stats.TopReferrers)
+}
+
+func TestMemoryRepositorySaveDeadURLAgain(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "deleted", "https://example.com/deleted", time.Time{}))
+	require.NoError(t, memoryRepo.BatchDeleteURLs(ctx, "u1", []string{"deleted"}))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "expired", "https://example.com/expired", time.Now().Add(-time.Hour)))
+
+	for _, originalURL := range []string{"https://example.com/deleted", "https://example.com/expired"} {
+		shortID, err := memoryRepo.GetShortIDByOriginalURL(ctx, originalURL)
+		require.NoError(t, err)
+		assert.Empty(t, shortID, "%s has no live link", originalURL)
+	}
+
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u2", "new-deleted", "https://example.com/deleted", time.Time{}))
+	saved, err := memoryRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "new-expired", OriginalURL: "https://example.com/expired", UserID: "u2"},
+		{ShortURL: "dup", OriginalURL: "https://example.com/deleted", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new-expired", saved[0].ShortURL)
+	assert.Equal(t, "new-deleted", saved[1].ShortURL)
+
+	// Tombstoning the expired link leaves the new one indexed.
+	expired, err := memoryRepo.ExpireURLs(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	shortID, err := memoryRepo.GetShortIDByOriginalURL(ctx, "https://example.com/expired")
+	require.NoError(t, err)
+	assert.Equal(t, "new-expired", shortID)
+}
+
+func TestMemoryRepositoryBatchDeleteURLs(t *testing.T) {
+	memoryRepo := NewMemoryRepository()
+	ctx := context.Background()
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "a", "https://example.com/a", time.Time{}))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u1", "b", "https://example.com/b", time.Time{}))
+	require.NoError(t, memoryRepo.SaveShortID(ctx, "u2", "c", "https://example.com/c", time.Time{}))
+
+	// c belongs to another user and missing does not exist: both are left alone.
+	require.NoError(t, memoryRepo.BatchDeleteURLs(ctx, "u1", []string{"a", "c", "missing"}))
+
+	_, _, exists, err := memoryRepo.GetOriginalURL(ctx, "a")
+	assert.ErrorIs(t, err, repo.ErrURLDeleted)
+	assert.False(t, exists)
+	for _, shortID := range []string{"b", "c"} {
+		originalURL, _, exists, err := memoryRepo.GetOriginalURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, "https://example.com/"+shortID, originalURL)
+	}
+	_, _, exists, err = memoryRepo.GetOriginalURL(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	records, err := memoryRepo.GetUserURLs(ctx, "u1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].ShortURL)
+}
@@ -0,0 +1,438 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the PostgreSQL advisory lock held while migrating, so
+// that instances starting together do not apply the same migration twice.
+const migrationLockKey int64 = 0x75726c5f6d6967 // "url_mig"
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`
+
+// Migration is a single versioned schema change read from
+// <dialect>/<version>_<name>.(up|down).sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// legacyColumns are the columns of the short_urls table of the README schema
+// used before migrations existed. The first migration brings such a table to
+// its own schema; a table with any other column is refused rather than
+// adopted as is.
+var legacyColumns = map[string]bool{"uuid": true, "short_url": true, "original_url": true}
+
+// conn runs the migrator's statements: either the pool, or the connection
+// holding the migration lock.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != "sqlite" && dialect != "postgres" {
+		return nil, fmt.Errorf("migrations are not supported for %q storage", dialect)
+	}
+
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// Run applies every pending migration. It is invoked from the fx graph on
+// startup and does nothing for storages without a database.
+func Run(cfg *config.Config, db *sql.DB) error {
+	if db == nil {
+		return nil
+	}
+
+	migrator, err := NewMigrator(db, cfg.StorageType)
+	if err != nil {
+		return err
+	}
+	return migrator.Up(context.Background())
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.Goto(ctx, m.latestVersion())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+
+	idx := m.indexOf(current)
+	switch {
+	case idx < 0:
+		return fmt.Errorf("applied migration version %d is unknown to this build", current)
+	case idx == 0:
+		return m.Goto(ctx, 0)
+	default:
+		return m.Goto(ctx, m.migrations[idx-1].Version)
+	}
+}
+
+// Goto migrates up or down until target is the latest applied version.
+// A target of 0 rolls back every migration. On PostgreSQL, the applied
+// migrations are read and changed under an advisory lock, so concurrent
+// calls run one after the other.
+func (m *Migrator) Goto(ctx context.Context, target int) error {
+	if target != 0 && m.indexOf(target) < 0 {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+
+	return m.locked(ctx, func(c conn) error {
+		return m.gotoLocked(ctx, c, target)
+	})
+}
+
+func (m *Migrator) gotoLocked(ctx context.Context, c conn, target int) error {
+	applied, err := m.applied(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if migration.Version == m.migrations[0].Version {
+			if err := m.checkExistingTable(ctx, c); err != nil {
+				return err
+			}
+		}
+		if err := m.apply(ctx, c, migration); err != nil {
+			return err
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.revert(ctx, c, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Version returns the latest applied migration version, or 0 when none is applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Status lists every known migration with its applied state.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, c conn, migration Migration) error {
+	err := inTx(ctx, c, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Applied migration")
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, c conn, migration Migration) error {
+	err := inTx(ctx, c, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			m.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("Reverted migration")
+	return nil
+}
+
+// checkExistingTable refuses a short_urls table created outside the
+// migrations unless it has the legacy README schema.
+func (m *Migrator) checkExistingTable(ctx context.Context, c conn) error {
+	query := "SELECT name FROM pragma_table_info('short_urls')"
+	if m.dialect == "postgres" {
+		query = `SELECT column_name FROM information_schema.columns
+                 WHERE table_schema = current_schema() AND table_name = 'short_urls'`
+	}
+	rows, err := c.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("could not inspect the short_urls table: %w", err)
+	}
+	defer rows.Close()
+
+	var unexpected []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return fmt.Errorf("could not inspect the short_urls table: %w", err)
+		}
+		if !legacyColumns[column] {
+			unexpected = append(unexpected, column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not inspect the short_urls table: %w", err)
+	}
+	if len(unexpected) > 0 {
+		return fmt.Errorf("table short_urls was not created by the migrations and has unexpected columns %s: "+
+			"migrate it by hand or move it aside", strings.Join(unexpected, ", "))
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, c conn) (map[int]time.Time, error) {
+	if _, err := c.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("could not create schema_migrations table: %w", err)
+	}
+
+	rows, err := c.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("could not scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// locked runs fn on a connection holding the migration lock on PostgreSQL,
+// and on the pool otherwise.
+func (m *Migrator) locked(ctx context.Context, fn func(c conn) error) error {
+	if m.dialect != "postgres" {
+		return fn(m.db)
+	}
+
+	// Advisory locks belong to a session, so everything runs on one
+	// connection.
+	lockConn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get a connection: %w", err)
+	}
+	defer lockConn.Close()
+
+	if _, err := lockConn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("could not take the migration lock: %w", err)
+	}
+	defer func() {
+		// The session ends when the connection is closed anyway, so a
+		// failed unlock only needs logging.
+		if _, err := lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			log.Error().Err(err).Msg("Could not release the migration lock")
+		}
+	}()
+
+	return fn(lockConn)
+}
+
+func inTx(ctx context.Context, c conn, fn func(tx *sql.Tx) error) error {
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind turns ? placeholders into the $n form expected by PostgreSQL.
+func (m *Migrator) rebind(query string) string {
+	if m.dialect != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (m *Migrator) indexOf(version int) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Migrator) latestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s migrations: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		rawVersion, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(rawVersion)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dialect, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %q: %w", fileName, err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableHasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		if name == column {
+			return true
+		}
+	}
+	require.NoError(t, rows.Err())
+	return false
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{"sqlite", "postgres"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := loadMigrations(dialect)
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+
+			for i, migration := range migrations {
+				assert.Equal(t, i+1, migration.Version)
+				assert.NotEmpty(t, migration.Name)
+				assert.NotEmpty(t, migration.Up)
+				assert.NotEmpty(t, migration.Down)
+			}
+		})
+	}
+}
+
+func TestNewMigratorUnsupportedDialect(t *testing.T) {
+	_, err := NewMigrator(nil, "memory")
+	assert.Error(t, err)
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+	latest := migrator.latestVersion()
+
+	require.NoError(t, migrator.Up(ctx))
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.True(t, tableHasColumn(t, db, "short_urls", "is_deleted"))
+
+	// Re-running is a no-op.
+	require.NoError(t, migrator.Up(ctx))
+
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('1', 'abc', 'https://example.com')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('2', 'abc', 'https://example.org')`)
+	assert.Error(t, err, "short_url must be unique")
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('3', 'def', 'https://example.com')`)
+	assert.Error(t, err, "original_url must be unique")
+
+	require.NoError(t, migrator.Down(ctx))
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrator.migrations[len(migrator.migrations)-2].Version, version)
+
+	require.NoError(t, migrator.Goto(ctx, 0))
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.False(t, tableHasColumn(t, db, "short_urls", "uuid"))
+
+	require.NoError(t, migrator.Down(ctx))
+}
+
+func TestMigratorGotoAndStatus(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Goto(ctx, 1))
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrator.migrations))
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	for _, status := range statuses[1:] {
+		assert.False(t, status.Applied)
+	}
+	assert.False(t, tableHasColumn(t, db, "short_urls", "is_deleted"))
+
+	assert.Error(t, migrator.Goto(ctx, 9999))
+}
+
+func TestMigratorUpAdoptsLegacyTable(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	// The schema the README had people create by hand before migrations.
+	_, err := db.Exec(`CREATE TABLE short_urls (
+        uuid UUID PRIMARY KEY,
+        short_url VARCHAR(255) NOT NULL UNIQUE,
+        original_url TEXT NOT NULL UNIQUE
+    )`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('1', 'abc', 'https://example.com')`)
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(ctx))
+
+	var originalURL string
+	require.NoError(t, db.QueryRow(`SELECT original_url FROM short_urls WHERE short_url = 'abc'`).Scan(&originalURL))
+	assert.Equal(t, "https://example.com", originalURL)
+	assert.True(t, tableHasColumn(t, db, "short_urls", "expires_at"))
+
+	var uniqueConstraints int
+	require.NoError(t, db.QueryRow(
+		`SELECT count(*) FROM pragma_index_list('short_urls') WHERE origin = 'u'`,
+	).Scan(&uniqueConstraints))
+	assert.Equal(t, 0, uniqueConstraints, "the UNIQUE column constraints must be gone")
+}
+
+func TestMigratorUpFixesAdoptedLegacyTable(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	// A legacy README table that an earlier 0001 adopted with its UNIQUE
+	// column constraints.
+	_, err := db.Exec(`CREATE TABLE short_urls (
+        uuid UUID PRIMARY KEY,
+        short_url VARCHAR(255) NOT NULL UNIQUE,
+        original_url TEXT NOT NULL UNIQUE
+    )`)
+	require.NoError(t, err)
+	_, err = db.Exec(createMigrationsTable)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (1, 'create_short_urls', ?)`, time.Now())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('1', 'abc', 'https://example.com')`)
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(ctx))
+
+	// The deleted link's URL can be stored again, but only once while live.
+	_, err = db.Exec(`UPDATE short_urls SET is_deleted = true WHERE short_url = 'abc'`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('2', 'def', 'https://example.com')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('3', 'ghi', 'https://example.com')`)
+	assert.Error(t, err, "original_url must be unique among live links")
+	_, err = db.Exec(`INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('4', 'abc', 'https://example.org')`)
+	assert.Error(t, err, "short_url must be unique")
+}
+
+func TestMigratorUpRefusesUnknownTable(t *testing.T) {
+	db := setupTestDB(t)
+	_, err := db.Exec(`CREATE TABLE short_urls (
+        uuid UUID PRIMARY KEY,
+        short_url VARCHAR(255) NOT NULL UNIQUE,
+        original_url TEXT NOT NULL UNIQUE,
+        owner TEXT
+    )`)
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db, "sqlite")
+	require.NoError(t, err)
+	err = migrator.Up(context.Background())
+	assert.ErrorContains(t, err, "unexpected columns owner")
+
+	version, err := migrator.Version(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.True(t, tableHasColumn(t, db, "short_urls", "owner"), "the table is left untouched")
+}
+
+func TestRun(t *testing.T) {
+	assert.NoError(t, Run(&config.Config{StorageType: "memory"}, nil))
+
+	db := setupTestDB(t)
+	require.NoError(t, Run(&config.Config{StorageType: "sqlite"}, db))
+	assert.True(t, tableHasColumn(t, db, "short_urls", "user_id"))
+}
+
+func TestRebind(t *testing.T) {
+	postgres := &Migrator{dialect: "postgres"}
+	assert.Equal(t, "VALUES ($1, $2)", postgres.rebind("VALUES (?, ?)"))
+
+	sqlite := &Migrator{dialect: "sqlite"}
+	assert.Equal(t, "VALUES (?, ?)", sqlite.rebind("VALUES (?, ?)"))
+}
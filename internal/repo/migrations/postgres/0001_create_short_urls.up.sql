@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS short_urls (
+    uuid UUID PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    original_url TEXT NOT NULL
+);
+
+-- A table created by hand from the README schema used before migrations
+-- declares its columns UNIQUE: those constraints are replaced by the indexes
+-- below, which later migrations manage.
+ALTER TABLE short_urls DROP CONSTRAINT IF EXISTS short_urls_short_url_key;
+ALTER TABLE short_urls DROP CONSTRAINT IF EXISTS short_urls_original_url_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_short_url_key ON short_urls (short_url);
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_key ON short_urls (original_url);
@@ -0,0 +1,4 @@
+-- Links stored before this migration keep a NULL created_at: their creation
+-- time is unknown.
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE short_urls ALTER COLUMN created_at SET DEFAULT now();
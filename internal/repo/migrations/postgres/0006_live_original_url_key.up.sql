@@ -0,0 +1,10 @@
+-- Only live links are deduplicated by original URL: once a link is deleted
+-- or expired, its URL can be shortened again.
+-- A table created from the README schema used before migrations, and adopted
+-- as is by an earlier 0001, enforces uniqueness with a constraint rather than
+-- an index.
+ALTER TABLE short_urls DROP CONSTRAINT IF EXISTS short_urls_original_url_key;
+DROP INDEX IF EXISTS short_urls_original_url_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_key ON short_urls (original_url)
+    WHERE is_deleted = false;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS short_urls (
+    uuid VARCHAR(36) PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    original_url TEXT NOT NULL
+);
+
+-- A table created by hand from the README schema used before migrations
+-- declares its columns UNIQUE, which SQLite cannot drop in place: the table
+-- is rebuilt so that only the indexes below enforce uniqueness.
+CREATE TABLE short_urls_0001 (
+    uuid VARCHAR(36) PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    original_url TEXT NOT NULL
+);
+INSERT INTO short_urls_0001 (uuid, short_url, original_url)
+    SELECT uuid, short_url, original_url FROM short_urls;
+DROP TABLE short_urls;
+ALTER TABLE short_urls_0001 RENAME TO short_urls;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_short_url_key ON short_urls (short_url);
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_key ON short_urls (original_url);
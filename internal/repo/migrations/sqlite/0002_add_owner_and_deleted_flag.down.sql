@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS short_urls_user_id_idx;
+
+ALTER TABLE short_urls DROP COLUMN is_deleted;
+ALTER TABLE short_urls DROP COLUMN user_id;
@@ -0,0 +1,4 @@
+ALTER TABLE short_urls ADD COLUMN user_id VARCHAR(36);
+ALTER TABLE short_urls ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS short_urls_user_id_idx ON short_urls (user_id, short_url);
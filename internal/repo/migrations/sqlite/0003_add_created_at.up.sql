@@ -0,0 +1,3 @@
+-- SQLite cannot add a column with a non-constant default: the repository
+-- sets created_at on insert. Links stored before this migration keep NULL.
+ALTER TABLE short_urls ADD COLUMN created_at TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS short_urls_expires_at_idx;
+
+ALTER TABLE short_urls DROP COLUMN expires_at;
@@ -0,0 +1,5 @@
+ALTER TABLE short_urls ADD COLUMN expires_at TIMESTAMP;
+
+-- The expiry janitor only looks for links that expire and are still live.
+CREATE INDEX IF NOT EXISTS short_urls_expires_at_idx ON short_urls (expires_at)
+    WHERE expires_at IS NOT NULL AND is_deleted = false;
@@ -0,0 +1,4 @@
+-- Fails when a URL was shortened again after its link was deleted.
+DROP INDEX IF EXISTS short_urls_original_url_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_key ON short_urls (original_url);
@@ -0,0 +1,25 @@
+-- Only live links are deduplicated by original URL: once a link is deleted
+-- or expired, its URL can be shortened again.
+-- A table created from the README schema used before migrations, and adopted
+-- as is by an earlier 0001, enforces uniqueness with UNIQUE column
+-- constraints, which SQLite cannot drop in place: the table is rebuilt.
+CREATE TABLE short_urls_0006 (
+    uuid VARCHAR(36) PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    original_url TEXT NOT NULL,
+    user_id VARCHAR(36),
+    is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+INSERT INTO short_urls_0006 (uuid, short_url, original_url, user_id, is_deleted, created_at, expires_at)
+    SELECT uuid, short_url, original_url, user_id, is_deleted, created_at, expires_at FROM short_urls;
+DROP TABLE short_urls;
+ALTER TABLE short_urls_0006 RENAME TO short_urls;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_short_url_key ON short_urls (short_url);
+CREATE UNIQUE INDEX IF NOT EXISTS short_urls_original_url_key ON short_urls (original_url)
+    WHERE is_deleted = false;
+CREATE INDEX IF NOT EXISTS short_urls_user_id_idx ON short_urls (user_id, short_url);
+CREATE INDEX IF NOT EXISTS short_urls_expires_at_idx ON short_urls (expires_at)
+    WHERE expires_at IS NOT NULL AND is_deleted = false;
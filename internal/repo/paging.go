@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"sort"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+// PageURLRecords orders records by short URL, the cursor key shared by every
+// backend, and keeps at most limit of them. In-process backends use it to
+// mirror the ORDER BY short_url LIMIT n queries of the SQL backends.
+func PageURLRecords(records []dto.URLRecord, limit int) []dto.URLRecord {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ShortURL < records[j].ShortURL
+	})
+	if limit >= 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
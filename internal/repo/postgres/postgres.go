@@ -3,8 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
 	"github.com/VladimirAzanza/url-shortener/internal/repo"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -20,34 +24,241 @@ func NewPostgreSQLRepository(db *sql.DB) repo.IURLRepository {
 	}
 }
 
-func (r *PostgreSQLRepository) SaveShortID(ctx context.Context, shortID, originalURL string) error {
-	return r.SaveBatchURL(ctx, shortID, originalURL)
-}
-
-func (r *PostgreSQLRepository) SaveBatchURL(ctx context.Context, shortID, originalURL string) error {
+func (r *PostgreSQLRepository) SaveShortID(
+	ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time,
+) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("could not begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO short_urls (uuid, short_url, original_url) 
-         VALUES ($1, $2, $3) 
-         ON CONFLICT (original_url) DO NOTHING`,
-		uuid.New().String(), shortID, originalURL)
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, expireOriginalURLs, pq.Array([]string{originalURL}), now); err != nil {
+		return fmt.Errorf("could not expire URL: %w", err)
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO short_urls (uuid, short_url, original_url, user_id, created_at, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (original_url) WHERE is_deleted = false DO NOTHING`,
+		uuid.New().String(), shortID, originalURL, userID, now, repo.NullTime(expiresAt))
 
+	if isShortURLConflict(err) {
+		return repo.ErrShortIDTaken
+	}
 	if err != nil {
 		return fmt.Errorf("could not insert URL: %w", err)
 	}
 
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not insert URL: %w", err)
+	}
+	if inserted == 0 {
+		// The original URL is already stored; read its short ID in the same
+		// transaction so that it is the row that won the conflict.
+		var existingShortID string
+		err := tx.QueryRowContext(ctx,
+			"SELECT short_url FROM short_urls WHERE original_url = $1 AND is_deleted = false",
+			originalURL).Scan(&existingShortID)
+		if err != nil {
+			return fmt.Errorf("could not read conflicting URL: %w", err)
+		}
+		return &repo.ErrURLConflict{ShortID: existingShortID}
+	}
+
 	return tx.Commit()
 }
 
+// expireOriginalURLs tombstones the expired live links of original URLs, so
+// that the URLs can be shortened again before the janitor gets to them.
+const expireOriginalURLs = `UPDATE short_urls SET is_deleted = true
+    WHERE original_url = ANY($1) AND is_deleted = false AND expires_at <= $2`
+
+// batchInsertRows keeps a multi-row insert under the limit of 65535 bind
+// parameters of the PostgreSQL protocol.
+const batchInsertRows = 1000
+
+// SaveURLBatch inserts the batch with multi-row INSERT statements in a single
+// transaction, then reads the short URLs of the original URLs that were
+// already stored.
+func (r *PostgreSQLRepository) SaveURLBatch(ctx context.Context, batch []dto.URLRecord) ([]dto.URLRecord, error) {
+	now := time.Now().UTC()
+	batch = repo.WithCreatedAt(batch, now)
+
+	// A live record repeating the original URL of an earlier one takes its
+	// short URL and is left out of the insert, whatever short URL it came
+	// with. Of the others, a repeated short URL would only be reported by the
+	// database without telling which item it was.
+	var (
+		records    = make([]dto.URLRecord, 0, len(batch))
+		indexes    = make([]int, 0, len(batch))
+		duplicates = make(map[int]int)
+		firsts     = make(map[string]int, len(batch))
+		positions  = make(map[string]int, len(batch))
+	)
+	for i, record := range batch {
+		if !record.IsDeleted {
+			if first, exists := firsts[record.OriginalURL]; exists {
+				duplicates[i] = first
+				continue
+			}
+			firsts[record.OriginalURL] = i
+		}
+		if _, exists := positions[record.ShortURL]; exists {
+			return nil, &repo.BatchItemError{Index: i, Err: repo.ErrShortIDTaken}
+		}
+		positions[record.ShortURL] = i
+		records = append(records, record)
+		indexes = append(indexes, i)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	originalURLs := make([]string, len(records))
+	for i, record := range records {
+		originalURLs[i] = record.OriginalURL
+	}
+	if _, err := tx.ExecContext(ctx, expireOriginalURLs, pq.Array(originalURLs), now); err != nil {
+		return nil, fmt.Errorf("could not expire URLs: %w", err)
+	}
+
+	saved := make([]dto.URLRecord, len(records))
+	inserted := make(map[string]bool, len(records))
+	for start := 0; start < len(records); start += batchInsertRows {
+		end := min(start+batchInsertRows, len(records))
+		if err := insertRows(ctx, tx, records[start:end], saved[start:end], inserted); err != nil {
+			var pqErr *pq.Error
+			if isShortURLConflict(err) && errors.As(err, &pqErr) {
+				if i, ok := positions[conflictingKey(pqErr)]; ok {
+					return nil, &repo.BatchItemError{Index: i, Err: repo.ErrShortIDTaken}
+				}
+				return nil, repo.ErrShortIDTaken
+			}
+			return nil, err
+		}
+	}
+
+	var pending []string
+	for i, record := range saved {
+		if !inserted[record.ShortURL] {
+			pending = append(pending, records[i].OriginalURL)
+		}
+	}
+	if len(pending) > 0 {
+		existing, err := shortIDsByOriginalURL(ctx, tx, pending)
+		if err != nil {
+			return nil, err
+		}
+		for i := range saved {
+			if !inserted[saved[i].ShortURL] {
+				saved[i].ShortURL = existing[saved[i].OriginalURL]
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit batch: %w", err)
+	}
+
+	result := make([]dto.URLRecord, len(batch))
+	for j, record := range saved {
+		result[indexes[j]] = record
+	}
+	for i, first := range duplicates {
+		result[i] = batch[i]
+		result[i].ShortURL = result[first].ShortURL
+	}
+	return result, nil
+}
+
+// insertRows inserts records with one statement, copies them to saved and
+// marks the short URLs of the rows actually inserted.
+func insertRows(ctx context.Context, tx *sql.Tx, records, saved []dto.URLRecord, inserted map[string]bool) error {
+	var (
+		query strings.Builder
+		args  = make([]any, 0, len(records)*7)
+	)
+	query.WriteString(
+		"INSERT INTO short_urls (uuid, short_url, original_url, user_id, created_at, expires_at, is_deleted) VALUES ")
+	for i, record := range records {
+		record.UUID = uuid.New().String()
+		saved[i] = record
+
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+		args = append(args, record.UUID, record.ShortURL, record.OriginalURL, record.UserID, record.CreatedAt,
+			repo.NullTime(record.ExpiresAt), record.IsDeleted)
+	}
+	query.WriteString(" ON CONFLICT (original_url) WHERE is_deleted = false DO NOTHING RETURNING short_url")
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortID string
+		if err := rows.Scan(&shortID); err != nil {
+			return fmt.Errorf("could not scan inserted URL: %w", err)
+		}
+		inserted[shortID] = true
+	}
+	return rows.Err()
+}
+
+func shortIDsByOriginalURL(ctx context.Context, tx *sql.Tx, originalURLs []string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT original_url, short_url FROM short_urls WHERE original_url = ANY($1) AND is_deleted = false",
+		pq.Array(originalURLs))
+	if err != nil {
+		return nil, fmt.Errorf("could not read conflicting URLs: %w", err)
+	}
+	defer rows.Close()
+
+	shortIDs := make(map[string]string, len(originalURLs))
+	for rows.Next() {
+		var originalURL, shortID string
+		if err := rows.Scan(&originalURL, &shortID); err != nil {
+			return nil, fmt.Errorf("could not scan conflicting URL: %w", err)
+		}
+		shortIDs[originalURL] = shortID
+	}
+	return shortIDs, rows.Err()
+}
+
+// conflictingKey extracts the value from a unique violation detail such as
+// "Key (short_url)=(abc) already exists.".
+func conflictingKey(err *pq.Error) string {
+	_, key, ok := strings.Cut(err.Detail, ")=(")
+	if !ok {
+		return ""
+	}
+	key, _, _ = strings.Cut(key, ") already exists")
+	return key
+}
+
+func isShortURLConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) &&
+		pqErr.Code == "23505" &&
+		pqErr.Constraint == "short_urls_short_url_key"
+}
+
 func (r *PostgreSQLRepository) GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
 	var shortID string
 	err := r.db.QueryRowContext(ctx,
-		"SELECT short_url FROM short_urls WHERE original_url = $1", originalURL).Scan(&shortID)
+		`SELECT short_url FROM short_urls
+         WHERE original_url = $1 AND is_deleted = false AND (expires_at IS NULL OR expires_at > $2)`,
+		originalURL, time.Now().UTC()).Scan(&shortID)
 
 	if err == sql.ErrNoRows {
 		return "", nil
@@ -58,32 +269,236 @@ func (r *PostgreSQLRepository) GetShortIDByOriginalURL(ctx context.Context, orig
 	return shortID, nil
 }
 
-func (r *PostgreSQLRepository) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
-	var originalURL string
+func (r *PostgreSQLRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+	var (
+		originalURL string
+		isDeleted   bool
+		expiresAt   sql.NullTime
+	)
 	err := r.db.QueryRowContext(ctx,
-		"SELECT original_url FROM short_urls WHERE short_url = $1",
-		shortID).Scan(&originalURL)
+		"SELECT original_url, is_deleted, expires_at FROM short_urls WHERE short_url = $1",
+		shortID).Scan(&originalURL, &isDeleted, &expiresAt)
 
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		return "", time.Time{}, false, nil
 	}
 	if err != nil {
-		return "", false, err
+		return "", time.Time{}, false, err
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		return "", time.Time{}, false, repo.ErrURLExpired
+	}
+	if isDeleted {
+		return "", time.Time{}, false, repo.ErrURLDeleted
 	}
 
-	return originalURL, true, nil
+	return originalURL, expiresAt.Time, true, nil
+}
+
+func (r *PostgreSQLRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT uuid, short_url, original_url, user_id FROM short_urls
+         WHERE user_id = $1 AND short_url > $2 AND is_deleted = false
+         ORDER BY short_url
+         LIMIT $3`,
+		userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]dto.URLRecord, 0, limit)
+	for rows.Next() {
+		var record dto.URLRecord
+		if err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &record.UserID); err != nil {
+			return nil, fmt.Errorf("could not scan user URL: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (r *PostgreSQLRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT uuid, short_url, original_url, COALESCE(user_id, ''), is_deleted, created_at, expires_at
+         FROM short_urls
+         WHERE short_url > $1
+         ORDER BY short_url
+         LIMIT $2`,
+		cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]dto.URLRecord, 0, limit)
+	for rows.Next() {
+		var (
+			record    dto.URLRecord
+			createdAt sql.NullTime
+			expiresAt sql.NullTime
+		)
+		err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &record.UserID,
+			&record.IsDeleted, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan URL: %w", err)
+		}
+		record.CreatedAt = createdAt.Time
+		record.ExpiresAt = expiresAt.Time
+		records = append(records, record)
+	}
+	return records, rows.Err()
 }
 
 func (r *PostgreSQLRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-func (r *PostgreSQLRepository) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+func (r *PostgreSQLRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
 	query := `
         UPDATE short_urls 
         SET is_deleted = true 
-        WHERE short_url = ANY($1) AND is_deleted = false`
+        WHERE short_url = ANY($1) AND user_id = $2 AND is_deleted = false`
 
-	_, err := r.db.ExecContext(ctx, query, pq.Array(shortURLs))
+	_, err := r.db.ExecContext(ctx, query, pq.Array(shortURLs), userID)
 	return err
 }
+
+func (r *PostgreSQLRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE short_urls
+         SET is_deleted = true
+         WHERE short_url IN (
+             SELECT short_url FROM short_urls
+             WHERE expires_at <= $1 AND is_deleted = false
+             LIMIT $2)`,
+		now.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("could not expire URLs: %w", err)
+	}
+
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("could not expire URLs: %w", err)
+	}
+	return int(expired), nil
+}
+
+// SaveClicks inserts the click events with multi-row INSERT statements.
+func (r *PostgreSQLRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
+	for start := 0; start < len(clicks); start += batchInsertRows {
+		end := min(start+batchInsertRows, len(clicks))
+
+		var (
+			query strings.Builder
+			args  = make([]any, 0, (end-start)*5)
+		)
+		query.WriteString("INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash) VALUES ")
+		for i, click := range clicks[start:end] {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+			args = append(args, click.ShortURL, click.ClickedAt.UTC(), click.Referrer, click.UserAgent, click.IPHash)
+		}
+
+		if _, err := r.db.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("could not insert clicks: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *PostgreSQLRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	var userID sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		"SELECT user_id FROM short_urls WHERE short_url = $1", shortID).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return userID.String, true, nil
+}
+
+func (r *PostgreSQLRepository) GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error) {
+	var (
+		stats dto.ClickStats
+		from  = query.From.UTC()
+		to    = query.To.UTC()
+	)
+	if query.Bucket.Duration() == 0 {
+		return stats, fmt.Errorf("unknown stats bucket %q", query.Bucket)
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT ip_hash) FROM clicks
+         WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3`,
+		shortID, from, to).Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return stats, fmt.Errorf("could not count clicks: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT date_trunc($4, clicked_at AT TIME ZONE 'UTC') AS bucket, COUNT(*) FROM clicks
+         WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3
+         GROUP BY bucket ORDER BY bucket`,
+		shortID, from, to, string(query.Bucket))
+	if err != nil {
+		return stats, fmt.Errorf("could not query click series: %w", err)
+	}
+	defer rows.Close()
+
+	stats.Series = make([]dto.SeriesPoint, 0)
+	for rows.Next() {
+		var point dto.SeriesPoint
+		if err := rows.Scan(&point.Start, &point.Clicks); err != nil {
+			return stats, fmt.Errorf("could not scan click series: %w", err)
+		}
+		point.Start = point.Start.UTC()
+		stats.Series = append(stats.Series, point)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("could not query click series: %w", err)
+	}
+
+	if stats.TopReferrers, err = r.topClickValues(ctx, "referrer", shortID, from, to, query.Top); err != nil {
+		return stats, err
+	}
+	if stats.TopUserAgents, err = r.topClickValues(ctx, "user_agent", shortID, from, to, query.Top); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// topClickValues returns the most frequent non-empty values of a column of
+// clicks. column is never user input.
+func (r *PostgreSQLRepository) topClickValues(
+	ctx context.Context, column, shortID string, from, to time.Time, limit int,
+) ([]dto.ValueCount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+column+`, COUNT(*) AS clicks FROM clicks
+         WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3 AND `+column+` <> ''
+         GROUP BY `+column+` ORDER BY clicks DESC, `+column+` LIMIT $4`,
+		shortID, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query top %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	values := make([]dto.ValueCount, 0)
+	for rows.Next() {
+		var value dto.ValueCount
+		if err := rows.Scan(&value.Value, &value.Clicks); err != nil {
+			return nil, fmt.Errorf("could not scan top %s: %w", column, err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not query top %s: %w", column, err)
+	}
+	return values, nil
+}
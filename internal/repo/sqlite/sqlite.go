@@ -3,10 +3,15 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
 	"github.com/VladimirAzanza/url-shortener/internal/repo"
 	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SQLiteRepository struct {
@@ -19,33 +24,137 @@ func NewSQLiteRepository(db *sql.DB) repo.IURLRepository {
 	}
 }
 
-func (r *SQLiteRepository) SaveShortID(ctx context.Context, shortID, originalURL string) error {
-	return r.SaveBatchURL(ctx, shortID, originalURL)
-}
-
-func (r *SQLiteRepository) SaveBatchURL(ctx context.Context, shortID, originalURL string) error {
+func (r *SQLiteRepository) SaveShortID(
+	ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time,
+) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("could not begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO short_urls (uuid, short_url, original_url) 
-         VALUES (?, ?, ?)`,
-		uuid.New().String(), shortID, originalURL)
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, expireOriginalURL, originalURL, now); err != nil {
+		return fmt.Errorf("could not expire URL: %w", err)
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO short_urls (uuid, short_url, original_url, user_id, created_at, expires_at)
+         VALUES (?, ?, ?, ?, ?, ?)
+         ON CONFLICT (original_url) WHERE is_deleted = false DO NOTHING`,
+		uuid.New().String(), shortID, originalURL, userID, now, repo.NullTime(expiresAt))
+
+	if isShortURLConflict(err) {
+		return repo.ErrShortIDTaken
+	}
+	if err != nil {
+		return fmt.Errorf("could not insert URL: %w", err)
+	}
 
+	inserted, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("could not insert URL: %w", err)
 	}
+	if inserted == 0 {
+		// The original URL is already stored; read its short ID in the same
+		// transaction so that it is the row that won the conflict.
+		var existingShortID string
+		err := tx.QueryRowContext(ctx, liveShortIDByOriginalURL, originalURL).Scan(&existingShortID)
+		if err != nil {
+			return fmt.Errorf("could not read conflicting URL: %w", err)
+		}
+		return &repo.ErrURLConflict{ShortID: existingShortID}
+	}
 
 	return tx.Commit()
 }
 
+// expireOriginalURL tombstones the expired live link of an original URL, if
+// any, so that the URL can be shortened again before the janitor gets to it.
+const expireOriginalURL = `UPDATE short_urls SET is_deleted = true
+    WHERE original_url = ? AND is_deleted = false AND expires_at <= ?`
+
+// liveShortIDByOriginalURL reads the short URL of the live link of an
+// original URL, the one the unique index deduplicates against.
+const liveShortIDByOriginalURL = "SELECT short_url FROM short_urls WHERE original_url = ? AND is_deleted = false"
+
+// SaveURLBatch inserts the batch in a single transaction through prepared
+// statements.
+func (r *SQLiteRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expire, err := tx.PrepareContext(ctx, expireOriginalURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not prepare expiry: %w", err)
+	}
+	defer expire.Close()
+
+	insert, err := tx.PrepareContext(ctx,
+		`INSERT INTO short_urls (uuid, short_url, original_url, user_id, created_at, expires_at, is_deleted)
+         VALUES (?, ?, ?, ?, ?, ?, ?)
+         ON CONFLICT (original_url) WHERE is_deleted = false DO NOTHING`)
+	if err != nil {
+		return nil, fmt.Errorf("could not prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	lookup, err := tx.PrepareContext(ctx, liveShortIDByOriginalURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not prepare lookup: %w", err)
+	}
+	defer lookup.Close()
+
+	now := time.Now().UTC()
+	saved := repo.WithCreatedAt(records, now)
+	for i, record := range saved {
+		if _, err := expire.ExecContext(ctx, record.OriginalURL, now); err != nil {
+			return nil, fmt.Errorf("could not expire URL: %w", err)
+		}
+		record.UUID = uuid.New().String()
+		result, err := insert.ExecContext(ctx,
+			record.UUID, record.ShortURL, record.OriginalURL, record.UserID, record.CreatedAt,
+			repo.NullTime(record.ExpiresAt), record.IsDeleted)
+		if isShortURLConflict(err) {
+			return nil, &repo.BatchItemError{Index: i, Err: repo.ErrShortIDTaken}
+		}
+		if err != nil {
+			return nil, &repo.BatchItemError{Index: i, Err: fmt.Errorf("could not insert URL: %w", err)}
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("could not insert URL: %w", err)
+		}
+		if inserted == 0 {
+			if err := lookup.QueryRowContext(ctx, record.OriginalURL).Scan(&record.ShortURL); err != nil {
+				return nil, fmt.Errorf("could not read conflicting URL: %w", err)
+			}
+		}
+		saved[i] = record
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit batch: %w", err)
+	}
+	return saved, nil
+}
+
+func isShortURLConflict(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "short_urls.short_url")
+}
+
 func (r *SQLiteRepository) GetShortIDByOriginalURL(ctx context.Context, originalURL string) (string, error) {
 	var shortID string
 	err := r.db.QueryRowContext(ctx,
-		"SELECT short_url FROM short_urls WHERE original_url = ?", originalURL).Scan(&shortID)
+		`SELECT short_url FROM short_urls
+         WHERE original_url = ? AND is_deleted = false AND (expires_at IS NULL OR expires_at > ?)`,
+		originalURL, time.Now().UTC()).Scan(&shortID)
 
 	if err == sql.ErrNoRows {
 		return "", nil
@@ -56,26 +165,263 @@ func (r *SQLiteRepository) GetShortIDByOriginalURL(ctx context.Context, original
 	return shortID, nil
 }
 
-func (r *SQLiteRepository) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
-	var originalURL string
+func (r *SQLiteRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+	var (
+		originalURL string
+		isDeleted   bool
+		expiresAt   sql.NullTime
+	)
 	err := r.db.QueryRowContext(ctx,
-		"SELECT original_url FROM short_urls WHERE short_url = ?",
-		shortID).Scan(&originalURL)
+		"SELECT original_url, is_deleted, expires_at FROM short_urls WHERE short_url = ?",
+		shortID).Scan(&originalURL, &isDeleted, &expiresAt)
 
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		return "", time.Time{}, false, nil
 	}
 	if err != nil {
-		return "", false, err
+		return "", time.Time{}, false, err
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		return "", time.Time{}, false, repo.ErrURLExpired
+	}
+	if isDeleted {
+		return "", time.Time{}, false, repo.ErrURLDeleted
 	}
 
-	return originalURL, true, nil
+	return originalURL, expiresAt.Time, true, nil
+}
+
+func (r *SQLiteRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT uuid, short_url, original_url, user_id FROM short_urls
+         WHERE user_id = ? AND short_url > ? AND is_deleted = false
+         ORDER BY short_url
+         LIMIT ?`,
+		userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]dto.URLRecord, 0, limit)
+	for rows.Next() {
+		var record dto.URLRecord
+		if err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &record.UserID); err != nil {
+			return nil, fmt.Errorf("could not scan user URL: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (r *SQLiteRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT uuid, short_url, original_url, COALESCE(user_id, ''), is_deleted, created_at, expires_at
+         FROM short_urls
+         WHERE short_url > ?
+         ORDER BY short_url
+         LIMIT ?`,
+		cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]dto.URLRecord, 0, limit)
+	for rows.Next() {
+		var (
+			record    dto.URLRecord
+			createdAt sql.NullTime
+			expiresAt sql.NullTime
+		)
+		err := rows.Scan(&record.UUID, &record.ShortURL, &record.OriginalURL, &record.UserID,
+			&record.IsDeleted, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan URL: %w", err)
+		}
+		record.CreatedAt = createdAt.Time
+		record.ExpiresAt = expiresAt.Time
+		records = append(records, record)
+	}
+	return records, rows.Err()
 }
 
 func (r *SQLiteRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-func (r *SQLiteRepository) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+func (r *SQLiteRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	if len(shortURLs) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(shortURLs)), ", ")
+	query := `
+        UPDATE short_urls 
+        SET is_deleted = true 
+        WHERE short_url IN (` + placeholders + `) AND user_id = ? AND is_deleted = false`
+
+	args := make([]any, 0, len(shortURLs)+1)
+	for _, shortURL := range shortURLs {
+		args = append(args, shortURL)
+	}
+	args = append(args, userID)
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *SQLiteRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE short_urls
+         SET is_deleted = true
+         WHERE short_url IN (
+             SELECT short_url FROM short_urls
+             WHERE expires_at <= ? AND is_deleted = false
+             LIMIT ?)`,
+		now.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("could not expire URLs: %w", err)
+	}
+
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("could not expire URLs: %w", err)
+	}
+	return int(expired), nil
+}
+
+// SaveClicks inserts the click events in a single transaction through a
+// prepared statement.
+func (r *SQLiteRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx,
+		"INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("could not prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	for _, click := range clicks {
+		if _, err := insert.ExecContext(ctx,
+			click.ShortURL, click.ClickedAt.UTC(), click.Referrer, click.UserAgent, click.IPHash); err != nil {
+			return fmt.Errorf("could not insert click: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit clicks: %w", err)
+	}
 	return nil
 }
+
+func (r *SQLiteRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	var userID sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		"SELECT user_id FROM short_urls WHERE short_url = ?", shortID).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return userID.String, true, nil
+}
+
+// bucketFormats truncate a click time to its bucket with strftime, in the
+// RFC 3339 layout.
+var bucketFormats = map[dto.StatsBucket]string{
+	dto.StatsBucketHour: "%Y-%m-%dT%H:00:00Z",
+	dto.StatsBucketDay:  "%Y-%m-%dT00:00:00Z",
+}
+
+func (r *SQLiteRepository) GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error) {
+	var (
+		stats dto.ClickStats
+		from  = query.From.UTC()
+		to    = query.To.UTC()
+	)
+	format, ok := bucketFormats[query.Bucket]
+	if !ok {
+		return stats, fmt.Errorf("unknown stats bucket %q", query.Bucket)
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT ip_hash) FROM clicks
+         WHERE short_url = ? AND clicked_at >= ? AND clicked_at < ?`,
+		shortID, from, to).Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return stats, fmt.Errorf("could not count clicks: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT strftime(?, clicked_at) AS bucket, COUNT(*) FROM clicks
+         WHERE short_url = ? AND clicked_at >= ? AND clicked_at < ?
+         GROUP BY bucket ORDER BY bucket`,
+		format, shortID, from, to)
+	if err != nil {
+		return stats, fmt.Errorf("could not query click series: %w", err)
+	}
+	defer rows.Close()
+
+	stats.Series = make([]dto.SeriesPoint, 0)
+	for rows.Next() {
+		var (
+			bucket string
+			point  dto.SeriesPoint
+		)
+		if err := rows.Scan(&bucket, &point.Clicks); err != nil {
+			return stats, fmt.Errorf("could not scan click series: %w", err)
+		}
+		if point.Start, err = time.Parse(time.RFC3339, bucket); err != nil {
+			return stats, fmt.Errorf("could not parse click bucket %q: %w", bucket, err)
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("could not query click series: %w", err)
+	}
+
+	if stats.TopReferrers, err = r.topClickValues(ctx, "referrer", shortID, from, to, query.Top); err != nil {
+		return stats, err
+	}
+	if stats.TopUserAgents, err = r.topClickValues(ctx, "user_agent", shortID, from, to, query.Top); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// topClickValues returns the most frequent non-empty values of a column of
+// clicks. column is never user input.
+func (r *SQLiteRepository) topClickValues(
+	ctx context.Context, column, shortID string, from, to time.Time, limit int,
+) ([]dto.ValueCount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+column+`, COUNT(*) AS clicks FROM clicks
+         WHERE short_url = ? AND clicked_at >= ? AND clicked_at < ? AND `+column+` <> ''
+         GROUP BY `+column+` ORDER BY clicks DESC, `+column+` LIMIT ?`,
+		shortID, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query top %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	values := make([]dto.ValueCount, 0)
+	for rows.Next() {
+		var value dto.ValueCount
+		if err := rows.Scan(&value.Value, &value.Clicks); err != nil {
+			return nil, fmt.Errorf("could not scan top %s: %w", column, err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not query top %s: %w", column, err)
+	}
+	return values, nil
+}
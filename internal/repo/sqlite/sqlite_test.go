@@ -0,0 +1,339 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestRepository(t *testing.T) *SQLiteRepository {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, migrations.Run(&config.Config{StorageType: "sqlite"}, db))
+	return NewSQLiteRepository(db).(*SQLiteRepository)
+}
+
+func TestSaveShortIDConflicts(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "spring-sale", "https://example.com", time.Time{}))
+
+	err := sqliteRepo.SaveShortID(ctx, "u2", "spring-sale", "https://example.org", time.Time{})
+	assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+
+	err = sqliteRepo.SaveShortID(ctx, "u2", "other", "https://example.com", time.Time{})
+	assert.NotErrorIs(t, err, repo.ErrShortIDTaken, "an original URL conflict is not a taken short ID")
+	var conflict *repo.ErrURLConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "spring-sale", conflict.ShortID)
+
+	originalURL, _, exists, err := sqliteRepo.GetOriginalURL(ctx, "spring-sale")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com", originalURL)
+}
+
+func TestSaveURLBatch(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "spring-sale", "https://example.com", time.Time{}))
+
+	saved, err := sqliteRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://example.org", UserID: "u2"},
+		{ShortURL: "def", OriginalURL: "https://example.com", UserID: "u2"},
+		{ShortURL: "ghi", OriginalURL: "https://example.org", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "spring-sale", "abc"}, []string{saved[0].ShortURL, saved[1].ShortURL, saved[2].ShortURL})
+
+	_, err = sqliteRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "jkl", OriginalURL: "https://example.net", UserID: "u2"},
+		{ShortURL: "spring-sale", OriginalURL: "https://example.io", UserID: "u2"},
+	})
+	assert.ErrorIs(t, err, repo.ErrShortIDTaken)
+	var itemErr *repo.BatchItemError
+	if assert.ErrorAs(t, err, &itemErr) {
+		assert.Equal(t, 1, itemErr.Index)
+	}
+
+	_, _, exists, err := sqliteRepo.GetOriginalURL(ctx, "jkl")
+	assert.NoError(t, err)
+	assert.False(t, exists, "a failed batch is rolled back")
+
+	// The hash strategy gives a URL repeated in a batch the same short ID.
+	saved, err = sqliteRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "mno", OriginalURL: "https://example.net", UserID: "u2"},
+		{ShortURL: "mno", OriginalURL: "https://example.net", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mno", "mno"}, []string{saved[0].ShortURL, saved[1].ShortURL})
+}
+
+func TestListURLs(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	before := time.Now().UTC().Add(-time.Second)
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "b", "https://example.com/b", time.Time{}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u2", "a", "https://example.com/a", time.Time{}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "c", "https://example.com/c", time.Time{}))
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"c"}))
+	// A link stored before created_at existed.
+	_, err := sqliteRepo.db.ExecContext(ctx,
+		"INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('legacy', 'd', 'https://example.com/d')")
+	require.NoError(t, err)
+
+	page, err := sqliteRepo.ListURLs(ctx, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "a", page[0].ShortURL)
+	assert.Equal(t, "u2", page[0].UserID)
+	assert.True(t, page[0].CreatedAt.After(before))
+	assert.Equal(t, "b", page[1].ShortURL)
+
+	page, err = sqliteRepo.ListURLs(ctx, "b", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "c", page[0].ShortURL)
+	assert.True(t, page[0].IsDeleted, "deleted links are listed")
+	assert.Equal(t, "d", page[1].ShortURL)
+	assert.Empty(t, page[1].UserID)
+	assert.True(t, page[1].CreatedAt.IsZero())
+
+	page, err = sqliteRepo.ListURLs(ctx, "d", 2)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func TestExpireURLs(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "old", "https://example.com/old", now.Add(-2*time.Hour)))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "older", "https://example.com/older", now.Add(-3*time.Hour)))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "later", "https://example.com/later", now.Add(time.Hour)))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "never", "https://example.com/never", time.Time{}))
+
+	_, _, exists, err := sqliteRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired, "expired links are gone before the janitor runs")
+	assert.False(t, exists)
+
+	expired, err := sqliteRepo.ExpireURLs(ctx, now, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = sqliteRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expired, err = sqliteRepo.ExpireURLs(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	_, _, _, err = sqliteRepo.GetOriginalURL(ctx, "old")
+	assert.ErrorIs(t, err, repo.ErrURLExpired)
+	originalURL, _, exists, err := sqliteRepo.GetOriginalURL(ctx, "later")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "https://example.com/later", originalURL)
+
+	records, err := sqliteRepo.GetUserURLs(ctx, "u1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "later", records[0].ShortURL)
+	assert.Equal(t, "never", records[1].ShortURL)
+}
+
+func TestSaveClicks(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	clickedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, sqliteRepo.SaveClicks(ctx, []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: clickedAt, Referrer: "https://news.example.com/", IPHash: "h1"},
+		{ShortURL: "b", ClickedAt: clickedAt, IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: clickedAt.Add(time.Minute), UserAgent: "curl/8.0", IPHash: "h2"},
+	}))
+
+	var (
+		count     int
+		referrer  string
+		storedAt  time.Time
+		userAgent string
+	)
+	require.NoError(t, sqliteRepo.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM clicks WHERE short_url = ?", "a").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.NoError(t, sqliteRepo.db.QueryRowContext(ctx,
+		"SELECT referrer, user_agent, clicked_at FROM clicks WHERE short_url = ? ORDER BY clicked_at LIMIT 1", "a").
+		Scan(&referrer, &userAgent, &storedAt))
+	assert.Equal(t, "https://news.example.com/", referrer)
+	assert.Empty(t, userAgent)
+	assert.True(t, clickedAt.Equal(storedAt))
+}
+
+func TestGetClickStats(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, sqliteRepo.SaveClicks(ctx, []dto.ClickEvent{
+		{ShortURL: "a", ClickedAt: base.Add(5 * time.Minute), Referrer: "https://r1.example/", UserAgent: "u1", IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: base.Add(30 * time.Minute), Referrer: "https://r1.example/", UserAgent: "u2", IPHash: "h2"},
+		{ShortURL: "a", ClickedAt: base.Add(70 * time.Minute), Referrer: "https://r2.example/", UserAgent: "u1", IPHash: "h1"},
+		{ShortURL: "a", ClickedAt: base.Add(25 * time.Hour), UserAgent: "u1", IPHash: "h3"},
+		{ShortURL: "a", ClickedAt: base.Add(-time.Hour), Referrer: "https://r3.example/", IPHash: "h4"},
+		{ShortURL: "a", ClickedAt: base.Add(48 * time.Hour), Referrer: "https://r3.example/", IPHash: "h4"},
+		{ShortURL: "b", ClickedAt: base.Add(time.Minute), Referrer: "https://r3.example/", IPHash: "h5"},
+	}))
+
+	stats, err := sqliteRepo.GetClickStats(ctx, "a", dto.StatsQuery{
+		Bucket: dto.StatsBucketHour, From: base, To: base.Add(48 * time.Hour), Top: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, dto.ClickStats{
+		TotalClicks:    4,
+		UniqueVisitors: 3,
+		Series: []dto.SeriesPoint{
+			{Start: base, Clicks: 2},
+			{Start: base.Add(time.Hour), Clicks: 1},
+			{Start: base.Add(25 * time.Hour), Clicks: 1},
+		},
+		TopReferrers:  []dto.ValueCount{{Value: "https://r1.example/", Clicks: 2}},
+		TopUserAgents: []dto.ValueCount{{Value: "u1", Clicks: 3}},
+	}, stats)
+
+	stats, err = sqliteRepo.GetClickStats(ctx, "a", dto.StatsQuery{
+		Bucket: dto.StatsBucketDay, From: base, To: base.Add(48 * time.Hour), Top: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []dto.SeriesPoint{
+		{Start: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Clicks: 3},
+		{Start: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), Clicks: 1},
+	}, stats.Series)
+	assert.Len(t, stats.TopReferrers, 2)
+
+	stats, err = sqliteRepo.GetClickStats(ctx, "missing", dto.StatsQuery{
+		Bucket: dto.StatsBucketDay, From: base, To: base.Add(48 * time.Hour), Top: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, dto.ClickStats{
+		Series:        []dto.SeriesPoint{},
+		TopReferrers:  []dto.ValueCount{},
+		TopUserAgents: []dto.ValueCount{},
+	}, stats)
+}
+
+func TestGetURLOwner(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "mine", "https://example.com", time.Time{}))
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"mine"}))
+	_, err := sqliteRepo.db.ExecContext(ctx,
+		"INSERT INTO short_urls (uuid, short_url, original_url) VALUES ('legacy-uuid', 'legacy', 'https://example.org')")
+	require.NoError(t, err)
+
+	owner, exists, err := sqliteRepo.GetURLOwner(ctx, "mine")
+	require.NoError(t, err)
+	assert.True(t, exists, "deleted links keep their owner")
+	assert.Equal(t, "u1", owner)
+
+	owner, exists, err = sqliteRepo.GetURLOwner(ctx, "legacy")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Empty(t, owner)
+
+	_, exists, err = sqliteRepo.GetURLOwner(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestSaveDeadURLAgain(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Hour)
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "deleted", "https://example.com/deleted", time.Time{}))
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"deleted"}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "expired", "https://example.com/expired", expired))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "gone", "https://example.com/batch", time.Time{}))
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"gone"}))
+
+	for _, originalURL := range []string{"https://example.com/deleted", "https://example.com/expired"} {
+		shortID, err := sqliteRepo.GetShortIDByOriginalURL(ctx, originalURL)
+		require.NoError(t, err)
+		assert.Empty(t, shortID, "%s has no live link", originalURL)
+	}
+
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u2", "new-deleted", "https://example.com/deleted", time.Time{}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u2", "new-expired", "https://example.com/expired", time.Time{}))
+	saved, err := sqliteRepo.SaveURLBatch(ctx, []dto.URLRecord{
+		{ShortURL: "new-batch", OriginalURL: "https://example.com/batch", UserID: "u2"},
+		{ShortURL: "dup", OriginalURL: "https://example.com/deleted", UserID: "u2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new-batch", saved[0].ShortURL)
+	assert.Equal(t, "new-deleted", saved[1].ShortURL)
+
+	// Saving the URL again tombstoned the expired link.
+	_, _, _, err = sqliteRepo.GetOriginalURL(ctx, "expired")
+	assert.ErrorIs(t, err, repo.ErrURLExpired)
+	records, err := sqliteRepo.ListURLs(ctx, "", 10)
+	require.NoError(t, err)
+	for _, record := range records {
+		assert.Equal(t, !strings.HasPrefix(record.ShortURL, "new-"), record.IsDeleted, record.ShortURL)
+	}
+	for originalURL, expected := range map[string]string{
+		"https://example.com/deleted": "new-deleted",
+		"https://example.com/expired": "new-expired",
+		"https://example.com/batch":   "new-batch",
+	} {
+		shortID, err := sqliteRepo.GetShortIDByOriginalURL(ctx, originalURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, shortID)
+	}
+}
+
+func TestBatchDeleteURLs(t *testing.T) {
+	sqliteRepo := setupTestRepository(t)
+	ctx := context.Background()
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "a", "https://example.com/a", time.Time{}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u1", "b", "https://example.com/b", time.Time{}))
+	require.NoError(t, sqliteRepo.SaveShortID(ctx, "u2", "c", "https://example.com/c", time.Time{}))
+
+	// c belongs to another user and missing does not exist: both are left alone.
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"a", "c", "missing"}))
+	// Deleting again is a no-op.
+	require.NoError(t, sqliteRepo.BatchDeleteURLs(ctx, "u1", []string{"a"}))
+
+	_, _, exists, err := sqliteRepo.GetOriginalURL(ctx, "a")
+	assert.ErrorIs(t, err, repo.ErrURLDeleted)
+	assert.False(t, exists)
+	for _, shortID := range []string{"b", "c"} {
+		originalURL, _, exists, err := sqliteRepo.GetOriginalURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, "https://example.com/"+shortID, originalURL)
+	}
+
+	records, err := sqliteRepo.GetUserURLs(ctx, "u1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].ShortURL)
+}
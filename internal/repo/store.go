@@ -0,0 +1,291 @@
+package repo
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+const recordStoreShards = 32
+
+// RecordStore is a concurrency-safe map of URL records keyed by short URL,
+// shared by the in-process backends. Records are spread over independently
+// locked shards so that requests for different short URLs do not contend.
+//
+// The store also keeps a reverse index from original URL to short URL so
+// that deduplication does not have to scan every record. Like the unique
+// original_url index of the SQL backends, it only holds live records, and
+// lookups skip the expired ones.
+type RecordStore struct {
+	shards    [recordStoreShards]recordShard
+	originals [recordStoreShards]originalShard
+}
+
+type recordShard struct {
+	mu      sync.RWMutex
+	records map[string]dto.URLRecord
+}
+
+type originalShard struct {
+	mu      sync.RWMutex
+	entries map[string]originalEntry
+}
+
+// originalEntry is the live record indexed under an original URL.
+type originalEntry struct {
+	shortID   string
+	expiresAt time.Time
+}
+
+func (e originalEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+func newOriginalEntry(record dto.URLRecord) originalEntry {
+	return originalEntry{shortID: record.ShortURL, expiresAt: record.ExpiresAt}
+}
+
+func NewRecordStore() *RecordStore {
+	s := &RecordStore{}
+	for i := range s.shards {
+		s.shards[i].records = make(map[string]dto.URLRecord)
+		s.originals[i].entries = make(map[string]originalEntry)
+	}
+	return s
+}
+
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % recordStoreShards
+}
+
+func (s *RecordStore) shard(shortID string) *recordShard {
+	return &s.shards[shardIndex(shortID)]
+}
+
+func (s *RecordStore) originalShard(originalURL string) *originalShard {
+	return &s.originals[shardIndex(originalURL)]
+}
+
+func (s *RecordStore) Get(shortID string) (dto.URLRecord, bool) {
+	shard := s.shard(shortID)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	record, ok := shard.records[shortID]
+	return record, ok
+}
+
+// Set stores record under its short URL, replacing any previous record.
+func (s *RecordStore) Set(record dto.URLRecord) {
+	shard := s.shard(record.ShortURL)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	previous, exists := shard.records[record.ShortURL]
+	shard.records[record.ShortURL] = record
+	s.reindex(previous, exists, record)
+}
+
+// Insert stores a live record unless its short URL is already stored, or its
+// original URL has a live record that has not expired at now, in which case
+// it returns ErrShortIDTaken or an *ErrURLConflict.
+func (s *RecordStore) Insert(record dto.URLRecord, now time.Time) error {
+	shard := s.shard(record.ShortURL)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := shard.records[record.ShortURL]; exists {
+		return ErrShortIDTaken
+	}
+
+	original := s.originalShard(record.OriginalURL)
+	original.mu.Lock()
+	defer original.mu.Unlock()
+
+	if entry, exists := original.entries[record.OriginalURL]; exists && !entry.expired(now) {
+		return &ErrURLConflict{ShortID: entry.shortID}
+	}
+	shard.records[record.ShortURL] = record
+	original.entries[record.OriginalURL] = newOriginalEntry(record)
+	return nil
+}
+
+// InsertBatch inserts records in a single pass over the locked store,
+// following the contract of IURLRepository.SaveURLBatch: nothing is stored if
+// a short URL is taken, and live records whose original URL has a live record
+// that has not expired at now come back with its short URL.
+func (s *RecordStore) InsertBatch(records []dto.URLRecord, now time.Time) ([]dto.URLRecord, error) {
+	// Shards are always locked in the same order, records before originals,
+	// so this cannot deadlock with single-record operations.
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		defer s.shards[i].mu.Unlock()
+	}
+	for i := range s.originals {
+		s.originals[i].mu.Lock()
+		defer s.originals[i].mu.Unlock()
+	}
+
+	saved, inserts, err := PlanBatch(records,
+		func(shortID string) bool {
+			_, exists := s.shard(shortID).records[shortID]
+			return exists
+		},
+		func(originalURL string) (string, bool) {
+			entry, exists := s.originalShard(originalURL).entries[originalURL]
+			return entry.shortID, exists && !entry.expired(now)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range inserts {
+		s.shard(record.ShortURL).records[record.ShortURL] = record
+		if !record.IsDeleted {
+			s.originalShard(record.OriginalURL).entries[record.OriginalURL] = newOriginalEntry(record)
+		}
+	}
+	return saved, nil
+}
+
+// Update atomically replaces the record stored under shortID with the result
+// of fn. fn receives the current record, if any, and returns the record to
+// store and whether to store it.
+func (s *RecordStore) Update(shortID string, fn func(record dto.URLRecord, exists bool) (dto.URLRecord, bool)) {
+	shard := s.shard(shortID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	current, exists := shard.records[shortID]
+	if record, ok := fn(current, exists); ok {
+		shard.records[shortID] = record
+		s.reindex(current, exists, record)
+	}
+}
+
+// ShortIDByOriginalURL returns the short URL of the live record most
+// recently stored for originalURL, unless it has expired at now.
+func (s *RecordStore) ShortIDByOriginalURL(originalURL string, now time.Time) (string, bool) {
+	shard := s.originalShard(originalURL)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, ok := shard.entries[originalURL]
+	if !ok || entry.expired(now) {
+		return "", false
+	}
+	return entry.shortID, true
+}
+
+// reindex moves the reverse index entry of a record that replaced previous,
+// dropping it when the record is deleted. The caller must hold the lock of
+// the record's shard, which is always taken before the reverse index locks.
+func (s *RecordStore) reindex(previous dto.URLRecord, existed bool, record dto.URLRecord) {
+	if existed && !previous.IsDeleted {
+		shard := s.originalShard(previous.OriginalURL)
+		shard.mu.Lock()
+		// A newer record of the same URL may have taken the entry over.
+		if shard.entries[previous.OriginalURL].shortID == previous.ShortURL {
+			delete(shard.entries, previous.OriginalURL)
+		}
+		shard.mu.Unlock()
+	}
+	if record.IsDeleted {
+		return
+	}
+
+	shard := s.originalShard(record.OriginalURL)
+	shard.mu.Lock()
+	shard.entries[record.OriginalURL] = newOriginalEntry(record)
+	shard.mu.Unlock()
+}
+
+// Expired returns the short URLs of up to limit records that expired at or
+// before now and are not deleted yet.
+func (s *RecordStore) Expired(now time.Time, limit int) []string {
+	var shortIDs []string
+	s.Range(func(record dto.URLRecord) bool {
+		if !record.IsDeleted && IsExpired(record, now) {
+			shortIDs = append(shortIDs, record.ShortURL)
+		}
+		return len(shortIDs) < limit
+	})
+	return shortIDs
+}
+
+// Range calls fn for every record until fn returns false. Each shard is
+// read-locked while it is visited, so fn must not modify the store.
+func (s *RecordStore) Range(fn func(record dto.URLRecord) bool) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for _, record := range shard.records {
+			if !fn(record) {
+				shard.mu.RUnlock()
+				return
+			}
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// Page returns up to limit records whose short URL sorts after cursor, in
+// short URL order. Every record is visited but only limit of them are kept,
+// so paging through the store never copies it whole.
+func (s *RecordStore) Page(cursor string, limit int) []dto.URLRecord {
+	if limit <= 0 {
+		return nil
+	}
+
+	page := make(recordMaxHeap, 0, limit)
+	s.Range(func(record dto.URLRecord) bool {
+		if record.ShortURL <= cursor {
+			return true
+		}
+		if len(page) < limit {
+			heap.Push(&page, record)
+		} else if record.ShortURL < page[0].ShortURL {
+			page[0] = record
+			heap.Fix(&page, 0)
+		}
+		return true
+	})
+
+	sort.Slice(page, func(i, j int) bool {
+		return page[i].ShortURL < page[j].ShortURL
+	})
+	return page
+}
+
+// recordMaxHeap keeps the record with the greatest short URL on top, so the
+// smallest ones seen so far stay in the heap.
+type recordMaxHeap []dto.URLRecord
+
+func (h recordMaxHeap) Len() int           { return len(h) }
+func (h recordMaxHeap) Less(i, j int) bool { return h[i].ShortURL > h[j].ShortURL }
+func (h recordMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *recordMaxHeap) Push(x any)        { *h = append(*h, x.(dto.URLRecord)) }
+func (h *recordMaxHeap) Pop() any {
+	old := *h
+	record := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return record
+}
+
+func (s *RecordStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		n += len(shard.records)
+		shard.mu.RUnlock()
+	}
+	return n
+}
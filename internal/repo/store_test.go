@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordStore(t *testing.T) {
+	store := NewRecordStore()
+
+	store.Set(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"})
+	record, ok := store.Get("abc")
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com", record.OriginalURL)
+
+	_, ok = store.Get("missing")
+	assert.False(t, ok)
+
+	store.Update("abc", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+		assert.True(t, exists)
+		record.IsDeleted = true
+		return record, true
+	})
+	record, _ = store.Get("abc")
+	assert.True(t, record.IsDeleted)
+
+	store.Update("missing", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+		assert.False(t, exists)
+		return record, false
+	})
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRecordStoreRangeStops(t *testing.T) {
+	store := NewRecordStore()
+	for i := 0; i < 100; i++ {
+		store.Set(dto.URLRecord{ShortURL: fmt.Sprintf("id%d", i)})
+	}
+
+	visited := 0
+	store.Range(func(record dto.URLRecord) bool {
+		visited++
+		return visited < 10
+	})
+	assert.Equal(t, 10, visited)
+}
+
+func TestRecordStoreConcurrentUpdate(t *testing.T) {
+	store := NewRecordStore()
+	store.Set(dto.URLRecord{ShortURL: "counter", OriginalURL: ""})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				store.Update("counter", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+					record.OriginalURL += "x"
+					return record, true
+				})
+				store.Set(dto.URLRecord{ShortURL: fmt.Sprintf("other-%d", j)})
+				store.Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	record, _ := store.Get("counter")
+	assert.Len(t, record.OriginalURL, 50*20)
+}
+
+func TestRecordStoreReverseIndex(t *testing.T) {
+	store := NewRecordStore()
+	now := time.Now()
+
+	store.Set(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"})
+	shortID, ok := store.ShortIDByOriginalURL("https://example.com", now)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", shortID)
+
+	// Re-pointing a short URL moves its entry.
+	store.Set(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.org"})
+	_, ok = store.ShortIDByOriginalURL("https://example.com", now)
+	assert.False(t, ok)
+	shortID, _ = store.ShortIDByOriginalURL("https://example.org", now)
+	assert.Equal(t, "abc", shortID)
+
+	// A newer short URL for the same original wins and is not dropped when
+	// the older one changes.
+	store.Set(dto.URLRecord{ShortURL: "def", OriginalURL: "https://example.org"})
+	store.Set(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.net"})
+	shortID, _ = store.ShortIDByOriginalURL("https://example.org", now)
+	assert.Equal(t, "def", shortID)
+	shortID, _ = store.ShortIDByOriginalURL("https://example.net", now)
+	assert.Equal(t, "abc", shortID)
+
+	// Soft deletes drop the entry.
+	store.Update("abc", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+		record.IsDeleted = true
+		return record, true
+	})
+	_, ok = store.ShortIDByOriginalURL("https://example.net", now)
+	assert.False(t, ok)
+
+	// Deleted records are not indexed.
+	store.Set(dto.URLRecord{ShortURL: "ghi", OriginalURL: "https://example.io", IsDeleted: true})
+	_, ok = store.ShortIDByOriginalURL("https://example.io", now)
+	assert.False(t, ok)
+
+	// Expired records are left out of lookups.
+	store.Set(dto.URLRecord{ShortURL: "jkl", OriginalURL: "https://example.dev", ExpiresAt: now.Add(time.Hour)})
+	shortID, ok = store.ShortIDByOriginalURL("https://example.dev", now)
+	assert.True(t, ok)
+	assert.Equal(t, "jkl", shortID)
+	_, ok = store.ShortIDByOriginalURL("https://example.dev", now.Add(time.Hour))
+	assert.False(t, ok)
+}
+
+func BenchmarkRecordStoreShortIDByOriginalURL(b *testing.B) {
+	for _, size := range []int{1_000, 100_000, 1_000_000} {
+		store := NewRecordStore()
+		for i := 0; i < size; i++ {
+			store.Set(dto.URLRecord{
+				ShortURL:    fmt.Sprintf("id%d", i),
+				OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			})
+		}
+		target := fmt.Sprintf("https://example.com/%d", size-1)
+
+		b.Run(fmt.Sprintf("records=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.ShortIDByOriginalURL(target, time.Time{})
+			}
+		})
+	}
+}
+
+func TestRecordStoreInsert(t *testing.T) {
+	store := NewRecordStore()
+	now := time.Now()
+
+	assert.NoError(t, store.Insert(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"}, now))
+	assert.ErrorIs(t, store.Insert(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.org"}, now), ErrShortIDTaken)
+
+	err := store.Insert(dto.URLRecord{ShortURL: "def", OriginalURL: "https://example.com"}, now)
+	var conflict *ErrURLConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "abc", conflict.ShortID)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRecordStoreInsertAfterDeleteOrExpiry(t *testing.T) {
+	store := NewRecordStore()
+	now := time.Now()
+
+	require.NoError(t, store.Insert(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"}, now))
+	store.Update("abc", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+		record.IsDeleted = true
+		return record, true
+	})
+	assert.NoError(t, store.Insert(dto.URLRecord{ShortURL: "def", OriginalURL: "https://example.com"}, now))
+
+	require.NoError(t, store.Insert(
+		dto.URLRecord{ShortURL: "ghi", OriginalURL: "https://example.org", ExpiresAt: now.Add(time.Hour)}, now))
+	var conflict *ErrURLConflict
+	assert.ErrorAs(t, store.Insert(dto.URLRecord{ShortURL: "jkl", OriginalURL: "https://example.org"}, now), &conflict)
+	assert.NoError(t, store.Insert(dto.URLRecord{ShortURL: "jkl", OriginalURL: "https://example.org"}, now.Add(time.Hour)))
+
+	// Tombstoning the expired record keeps the entry of the new one.
+	store.Update("ghi", func(record dto.URLRecord, exists bool) (dto.URLRecord, bool) {
+		record.IsDeleted = true
+		return record, true
+	})
+	shortID, _ := store.ShortIDByOriginalURL("https://example.org", now)
+	assert.Equal(t, "jkl", shortID)
+}
+
+func TestRecordStoreConcurrentInsert(t *testing.T) {
+	store := NewRecordStore()
+
+	var (
+		wg       sync.WaitGroup
+		inserted atomic.Int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every goroutine races to shorten the same URL.
+			if store.Insert(dto.URLRecord{ShortURL: fmt.Sprintf("id%d", i), OriginalURL: "https://example.com"}, time.Now()) == nil {
+				inserted.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), inserted.Load())
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRecordStoreInsertBatch(t *testing.T) {
+	store := NewRecordStore()
+	now := time.Now()
+	assert.NoError(t, store.Insert(dto.URLRecord{ShortURL: "abc", OriginalURL: "https://example.com"}, now))
+
+	saved, err := store.InsertBatch([]dto.URLRecord{
+		{ShortURL: "new1", OriginalURL: "https://example.org"},
+		{ShortURL: "new2", OriginalURL: "https://example.com"},
+		{ShortURL: "new3", OriginalURL: "https://example.org"},
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new1", "abc", "new1"}, []string{saved[0].ShortURL, saved[1].ShortURL, saved[2].ShortURL})
+	assert.Equal(t, 2, store.Len())
+
+	_, err = store.InsertBatch([]dto.URLRecord{
+		{ShortURL: "new4", OriginalURL: "https://example.net"},
+		{ShortURL: "abc", OriginalURL: "https://example.io"},
+	}, now)
+	var itemErr *BatchItemError
+	if assert.ErrorAs(t, err, &itemErr) {
+		assert.Equal(t, 1, itemErr.Index)
+	}
+	assert.ErrorIs(t, err, ErrShortIDTaken)
+	_, exists := store.Get("new4")
+	assert.False(t, exists, "a failed batch stores nothing")
+
+	_, err = store.InsertBatch([]dto.URLRecord{
+		{ShortURL: "dup", OriginalURL: "https://example.net"},
+		{ShortURL: "dup", OriginalURL: "https://example.io"},
+	}, now)
+	assert.ErrorIs(t, err, ErrShortIDTaken)
+	assert.Equal(t, 2, store.Len())
+
+	// Deleted records are stored as they are, and left out of deduplication.
+	saved, err = store.InsertBatch([]dto.URLRecord{
+		{ShortURL: "old1", OriginalURL: "https://example.com", IsDeleted: true},
+		{ShortURL: "old2", OriginalURL: "https://example.net", IsDeleted: true},
+		{ShortURL: "new5", OriginalURL: "https://example.net"},
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old1", "old2", "new5"}, []string{saved[0].ShortURL, saved[1].ShortURL, saved[2].ShortURL})
+	assert.Equal(t, 5, store.Len())
+	shortID, _ := store.ShortIDByOriginalURL("https://example.com", now)
+	assert.Equal(t, "abc", shortID)
+}
+
+func TestRecordStorePage(t *testing.T) {
+	store := NewRecordStore()
+	for i := 0; i < 100; i++ {
+		store.Set(dto.URLRecord{ShortURL: fmt.Sprintf("id%03d", i), OriginalURL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+
+	var shortIDs []string
+	cursor := ""
+	for {
+		page := store.Page(cursor, 30)
+		if len(page) == 0 {
+			break
+		}
+		for _, record := range page {
+			shortIDs = append(shortIDs, record.ShortURL)
+		}
+		cursor = page[len(page)-1].ShortURL
+	}
+
+	require.Len(t, shortIDs, 100)
+	for i, shortID := range shortIDs {
+		assert.Equal(t, fmt.Sprintf("id%03d", i), shortID)
+	}
+	assert.Empty(t, store.Page("", 0))
+}
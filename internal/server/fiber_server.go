@@ -9,48 +9,74 @@ import (
 	_ "github.com/VladimirAzanza/url-shortener/docs"
 	"github.com/VladimirAzanza/url-shortener/internal/controller"
 	"github.com/VladimirAzanza/url-shortener/internal/middleware"
+	"github.com/VladimirAzanza/url-shortener/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/swagger"
 	"go.uber.org/fx"
 )
 
+// maxBodySize is the largest request body accepted outside of imports: 10 MB.
+const maxBodySize = 10 * 1024 * 1024
+
 // ./shortener -a :8081
 // SERVER_ADDRESS=:8082 ./shortener
-func NewFiberServer(urlController *controller.FiberURLController) *fiber.App {
+func NewFiberServer(cfg *config.Config, urlController *controller.FiberURLController) *fiber.App {
 	app := fiber.New(fiber.Config{
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  30 * time.Second,
-		//  Max body limit set to 10 MB
-		BodyLimit: 10 * 1024 * 1024,
+		// Bodies larger than BodyLimit are streamed to the handler instead of
+		// rejected, so the import can read them line by line. Every other
+		// route enforces maxBodySize itself.
+		BodyLimit:         maxBodySize,
+		StreamRequestBody: true,
+		// X-Forwarded-* headers are honored from the configured proxies only,
+		// so that clients cannot choose the host of the links built for them
+		// nor the IP address their clicks are recorded with.
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxyList(),
+		ProxyHeader:             fiber.HeaderXForwardedFor,
+		EnableIPValidation:      true,
 	})
+	limitBody := middleware.MaxBodySize(maxBodySize)
 
 	app.Use(compress.New(compress.Config{
 		Level: compress.LevelBestSpeed,
 	}))
 
 	app.Use(middleware.MiddlewareZerolog())
+	app.Use(middleware.MiddlewareAuth(cfg.SecretKey))
 
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
 	app.Get("/ping", urlController.GetDBPing)
 	app.Get("/:id", urlController.HandleGet)
-	app.Post("/", urlController.HandlePost)
+	app.Post("/", limitBody, urlController.HandlePost)
 
 	api := app.Group("/api")
 	{
-		api.Post("/shorten", urlController.HandleAPIPost)
-		api.Post("/shorten/batch", urlController.HandleAPIPostBatch)
-		api.Post("/user/urls", urlController.HandleAPIDeleteBatch)
+		api.Post("/shorten", limitBody, urlController.HandleAPIPost)
+		api.Post("/shorten/batch", limitBody, urlController.HandleAPIPostBatch)
+		api.Post("/shorten/import", urlController.HandleAPIImport)
+		api.Get("/user/urls", middleware.RequireUserID(), urlController.HandleAPIGetUserURLs)
+		api.Delete("/user/urls", middleware.RequireUserID(), limitBody, urlController.HandleAPIDeleteBatch)
+		api.Get("/urls/:id/stats", middleware.RequireUserID(), urlController.HandleAPIGetURLStats)
+		api.Get("/admin/export", middleware.RequireAdminToken(cfg.AdminToken), urlController.HandleAPIExport)
 	}
 
 	return app
 }
 
-func StartFiberServer(lc fx.Lifecycle, app *fiber.App, cfg *config.Config, db *sql.DB) {
+func StartFiberServer(
+	lc fx.Lifecycle, app *fiber.App, cfg *config.Config, db *sql.DB,
+	deleter *services.URLDeleter, janitor *services.URLJanitor, clicks *services.ClickRecorder,
+) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
+			deleter.Start()
+			janitor.Start()
+			clicks.Start()
 			go app.Listen(cfg.ServerAddress)
 			return nil
 		},
@@ -58,6 +84,15 @@ func StartFiberServer(lc fx.Lifecycle, app *fiber.App, cfg *config.Config, db *s
 			if err := app.Shutdown(); err != nil {
 				return err
 			}
+			if err := deleter.Stop(ctx); err != nil {
+				return err
+			}
+			if err := janitor.Stop(ctx); err != nil {
+				return err
+			}
+			if err := clicks.Stop(ctx); err != nil {
+				return err
+			}
 			if db != nil {
 				return db.Close()
 			}
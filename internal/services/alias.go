@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidAlias = errors.New("invalid alias")
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{2,63}$`)
+
+// reservedAliases are path segments served by the application itself, which
+// a custom alias must not shadow.
+var reservedAliases = map[string]bool{
+	"api":         true,
+	"ping":        true,
+	"swagger":     true,
+	"user":        true,
+	"admin":       true,
+	"static":      true,
+	"health":      true,
+	"metrics":     true,
+	"favicon.ico": true,
+	"robots.txt":  true,
+}
+
+// ValidateAlias checks a custom short ID: 3 to 64 letters, digits, '-' or
+// '_', starting with a letter or digit, and not a reserved word.
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("%w: %q must be 3-64 letters, digits, '-' or '_'", ErrInvalidAlias, alias)
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias   string
+		isValid bool
+	}{
+		{alias: "spring-sale", isValid: true},
+		{alias: "Promo_2025", isValid: true},
+		{alias: "abc", isValid: true},
+		{alias: "ab", isValid: false},
+		{alias: "-sale", isValid: false},
+		{alias: "spring sale", isValid: false},
+		{alias: "sale/2025", isValid: false},
+		{alias: "ñandú", isValid: false},
+		{alias: "api", isValid: false},
+		{alias: "Swagger", isValid: false},
+		{alias: "ping", isValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			err := ValidateAlias(tt.alias)
+			if tt.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidAlias)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	clickQueueSize     = 4096
+	clickBatchSize     = 500
+	clickFlushInterval = time.Second
+	clickFlushTimeout  = 10 * time.Second
+	// maxClickHeaderLength bounds the referrer and user agent kept per click.
+	maxClickHeaderLength = 512
+)
+
+// ClickRecorder collects click events off the redirect path. Record hands
+// events to a buffered channel without ever blocking: when the buffer is
+// full the event is dropped and counted. A single worker writes the events
+// to the repository in batches, either when clickBatchSize events are
+// pending or every clickFlushInterval.
+type ClickRecorder struct {
+	repo          repo.IURLRepository
+	events        chan dto.ClickEvent
+	done          chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+
+	dropped atomic.Uint64
+	// reported is the value of dropped last logged by the worker.
+	reported uint64
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewClickRecorder(repo repo.IURLRepository) *ClickRecorder {
+	return &ClickRecorder{
+		repo:          repo,
+		events:        make(chan dto.ClickEvent, clickQueueSize),
+		done:          make(chan struct{}),
+		batchSize:     clickBatchSize,
+		flushInterval: clickFlushInterval,
+	}
+}
+
+// Start launches the background worker. It must be called once.
+func (r *ClickRecorder) Start() {
+	go r.run()
+}
+
+// Stop stops accepting events and waits until every queued event has been
+// written or ctx is done.
+func (r *ClickRecorder) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.stopped {
+		r.stopped = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Record queues a click event. It never blocks: the event is dropped when
+// the queue is full or the recorder is stopped, and reported as false.
+func (r *ClickRecorder) Record(event dto.ClickEvent) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.stopped {
+		select {
+		case r.events <- event:
+			return true
+		default:
+		}
+	}
+	r.dropped.Add(1)
+	return false
+}
+
+// Dropped returns the number of click events dropped since the start.
+func (r *ClickRecorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+func (r *ClickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	pending := make([]dto.ClickEvent, 0, r.batchSize)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		r.flush(pending)
+		pending = make([]dto.ClickEvent, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				flush()
+				r.reportDropped()
+				return
+			}
+			pending = append(pending, event)
+			if len(pending) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			r.reportDropped()
+		}
+	}
+}
+
+func (r *ClickRecorder) flush(events []dto.ClickEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+	defer cancel()
+
+	if err := r.repo.SaveClicks(ctx, events); err != nil {
+		log.Error().Err(err).Int("count", len(events)).Msg("Error saving clicks")
+	}
+}
+
+// reportDropped logs the events dropped since the last report, so that a
+// full queue does not turn into one log line per redirect.
+func (r *ClickRecorder) reportDropped() {
+	dropped := r.dropped.Load()
+	if dropped == r.reported {
+		return
+	}
+	log.Warn().Uint64("count", dropped-r.reported).Uint64("total", dropped).Msg("Dropped clicks")
+	r.reported = dropped
+}
+
+// hashIP hashes an IP address with a key, so that visitors can be counted
+// without storing their addresses: a plain hash of the small IPv4 space
+// would be easy to reverse.
+func hashIP(key, ip string) string {
+	mac := hmac.New(sha256.New, []byte("click-ip:"+key))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// truncateHeader cuts a header value to maxClickHeaderLength bytes without
+// splitting a UTF-8 sequence.
+func truncateHeader(value string) string {
+	if len(value) <= maxClickHeaderLength {
+		return value
+	}
+	cut := maxClickHeaderLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
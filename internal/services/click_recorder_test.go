@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func setupTestClickRecorder(t *testing.T, batchSize int, flushInterval time.Duration) (*ClickRecorder, *mocks.MockIURLRepository) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+
+	recorder := NewClickRecorder(mockRepo)
+	recorder.batchSize = batchSize
+	recorder.flushInterval = flushInterval
+	return recorder, mockRepo
+}
+
+func testClick(shortID string) dto.ClickEvent {
+	return dto.ClickEvent{ShortURL: shortID, ClickedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func TestClickRecorderFlushesBySize(t *testing.T) {
+	recorder, mockRepo := setupTestClickRecorder(t, 3, time.Hour)
+
+	flushed := make(chan []dto.ClickEvent, 1)
+	mockRepo.EXPECT().
+		SaveClicks(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, clicks []dto.ClickEvent) error {
+			flushed <- clicks
+			return nil
+		}).
+		Times(1)
+
+	recorder.Start()
+	defer recorder.Stop(context.Background())
+
+	assert.True(t, recorder.Record(testClick("a")))
+	assert.True(t, recorder.Record(testClick("b")))
+	assert.True(t, recorder.Record(testClick("a")))
+
+	select {
+	case clicks := <-flushed:
+		assert.Equal(t, []dto.ClickEvent{testClick("a"), testClick("b"), testClick("a")}, clicks)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed by size")
+	}
+}
+
+func TestClickRecorderFlushesByTime(t *testing.T) {
+	recorder, mockRepo := setupTestClickRecorder(t, 100, 20*time.Millisecond)
+
+	flushed := make(chan []dto.ClickEvent, 1)
+	mockRepo.EXPECT().
+		SaveClicks(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, clicks []dto.ClickEvent) error {
+			flushed <- clicks
+			return nil
+		}).
+		Times(1)
+
+	recorder.Start()
+	defer recorder.Stop(context.Background())
+
+	assert.True(t, recorder.Record(testClick("a")))
+
+	select {
+	case clicks := <-flushed:
+		assert.Equal(t, []dto.ClickEvent{testClick("a")}, clicks)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed by time")
+	}
+}
+
+func TestClickRecorderFlushesOnStop(t *testing.T) {
+	recorder, mockRepo := setupTestClickRecorder(t, 100, time.Hour)
+
+	mockRepo.EXPECT().
+		SaveClicks(gomock.Any(), []dto.ClickEvent{testClick("a"), testClick("b")}).
+		Return(errors.New("db error")).
+		Times(1)
+
+	recorder.Start()
+
+	assert.True(t, recorder.Record(testClick("a")))
+	assert.True(t, recorder.Record(testClick("b")))
+	assert.NoError(t, recorder.Stop(context.Background()))
+
+	assert.False(t, recorder.Record(testClick("c")))
+	assert.Equal(t, uint64(1), recorder.Dropped())
+}
+
+func TestClickRecorderDropsWhenFull(t *testing.T) {
+	// The worker is not started, so nothing drains the queue.
+	recorder, _ := setupTestClickRecorder(t, 100, time.Hour)
+	recorder.events = make(chan dto.ClickEvent, 2)
+
+	assert.True(t, recorder.Record(testClick("a")))
+	assert.True(t, recorder.Record(testClick("b")))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 3 {
+			assert.False(t, recorder.Record(testClick("c")))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Record blocked on a full queue")
+	}
+	assert.Equal(t, uint64(3), recorder.Dropped())
+}
+
+func TestRecordClick(t *testing.T) {
+	s, _, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	s.now = func() time.Time { return now }
+
+	s.RecordClick(testShortID, "https://news.example.com/", strings.Repeat("é", maxClickHeaderLength), "203.0.113.7")
+
+	select {
+	case click := <-s.clicks.events:
+		assert.Equal(t, testShortID, click.ShortURL)
+		assert.Equal(t, now.UTC(), click.ClickedAt)
+		assert.Equal(t, "https://news.example.com/", click.Referrer)
+		assert.Equal(t, strings.Repeat("é", maxClickHeaderLength/2), click.UserAgent)
+		assert.Equal(t, hashIP(getTestConfig().SecretKey, "203.0.113.7"), click.IPHash)
+		assert.NotContains(t, click.IPHash, "203.0.113.7")
+	default:
+		t.Fatal("click was not queued")
+	}
+}
+
+func TestHashIP(t *testing.T) {
+	hash := hashIP("key", "203.0.113.7")
+	require.Len(t, hash, 32)
+	assert.Equal(t, hash, hashIP("key", "203.0.113.7"))
+	assert.NotEqual(t, hash, hashIP("key", "203.0.113.8"))
+	assert.NotEqual(t, hash, hashIP("other key", "203.0.113.7"))
+}
+
+func TestTruncateHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "Short", value: "Mozilla/5.0", expected: "Mozilla/5.0"},
+		{name: "At the limit", value: strings.Repeat("a", maxClickHeaderLength), expected: strings.Repeat("a", maxClickHeaderLength)},
+		{name: "Too long", value: strings.Repeat("a", maxClickHeaderLength+1), expected: strings.Repeat("a", maxClickHeaderLength)},
+		{
+			name:     "Multi-byte character at the limit",
+			value:    strings.Repeat("a", maxClickHeaderLength-1) + "é",
+			expected: strings.Repeat("a", maxClickHeaderLength-1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, truncateHeader(tt.value))
+		})
+	}
+}
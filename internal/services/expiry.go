@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidExpiry = errors.New("invalid expiry")
+
+// maxTTL bounds ttl_seconds, far enough to never be a real constraint and
+// close enough to keep the expiry time representable.
+const maxTTL = 100 * 365 * 24 * time.Hour
+
+// expiryTime resolves the expiry of a request: an absolute expires_at, a
+// ttl_seconds counted from now, or neither for a link that never expires,
+// reported as the zero time.
+func expiryTime(expiresAt *time.Time, ttlSeconds int64, now time.Time) (time.Time, error) {
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return time.Time{}, fmt.Errorf("%w: set either expires_at or ttl_seconds, not both", ErrInvalidExpiry)
+	case ttlSeconds < 0 || ttlSeconds > int64(maxTTL/time.Second):
+		return time.Time{}, fmt.Errorf("%w: ttl_seconds must be between 1 and %d", ErrInvalidExpiry, int64(maxTTL/time.Second))
+	case ttlSeconds > 0:
+		return now.Add(time.Duration(ttlSeconds) * time.Second).UTC(), nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return time.Time{}, fmt.Errorf("%w: expires_at %s is not in the future", ErrInvalidExpiry, expiresAt.Format(time.RFC3339))
+	case expiresAt != nil:
+		return expiresAt.UTC(), nil
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryTime(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	local := time.Date(2026, 1, 2, 12, 0, 0, 0, time.FixedZone("UTC+5", 5*60*60))
+
+	tests := []struct {
+		name          string
+		expiresAt     *time.Time
+		ttlSeconds    int64
+		expected      time.Time
+		expectedError string
+	}{
+		{name: "Never expires", expected: time.Time{}},
+		{name: "TTL", ttlSeconds: 60, expected: now.Add(time.Minute)},
+		{name: "Absolute expiry", expiresAt: &future, expected: future},
+		{name: "Absolute expiry in another zone", expiresAt: &local, expected: local.UTC()},
+		{name: "Both set", expiresAt: &future, ttlSeconds: 60, expectedError: "not both"},
+		{name: "Negative TTL", ttlSeconds: -1, expectedError: "ttl_seconds must be between"},
+		{name: "TTL too long", ttlSeconds: int64(maxTTL/time.Second) + 1, expectedError: "ttl_seconds must be between"},
+		{name: "Expiry in the past", expiresAt: &past, expectedError: "not in the future"},
+		{name: "Expiry now", expiresAt: &now, expectedError: "not in the future"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt, err := expiryTime(tt.expiresAt, tt.ttlSeconds, now)
+
+			if tt.expectedError != "" {
+				assert.ErrorIs(t, err, ErrInvalidExpiry)
+				assert.ErrorContains(t, err, tt.expectedError)
+				assert.True(t, expiresAt.IsZero())
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.expected.Equal(expiresAt), "expected %s, got %s", tt.expected, expiresAt)
+		})
+	}
+}
+
+func TestShortenAPIURLWithExpiry(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	mockRepo.EXPECT().GetShortIDByOriginalURL(ctx, "https://example.com/sale").Return("", nil)
+	mockRepo.EXPECT().SaveShortID(ctx, testUserID, testShortID, "https://example.com/sale", now.Add(time.Hour)).Return(nil)
+
+	shortID, err := s.ShortenAPIURL(ctx, testUserID, &dto.ShortenRequestDTO{URL: "https://example.com/sale", TTLSeconds: 3600})
+
+	assert.NoError(t, err)
+	assert.Equal(t, testShortID, shortID)
+}
+
+func TestShortenAPIURLInvalidExpiry(t *testing.T) {
+	// The repository mock expects no call: invalid expiries never reach it.
+	s, _, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	shortID, err := s.ShortenAPIURL(context.Background(), testUserID, &dto.ShortenRequestDTO{URL: "https://example.com", TTLSeconds: -5})
+
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+	assert.Empty(t, shortID)
+}
+
+func TestBatchShortenURLsWithExpiry(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	expiresAt := now.Add(24 * time.Hour)
+
+	mockRepo.EXPECT().
+		SaveURLBatch(ctx, []dto.URLRecord{
+			{ShortURL: testShortID, OriginalURL: "https://example.com/a", UserID: testUserID, ExpiresAt: now.Add(time.Minute)},
+			{ShortURL: "sale", OriginalURL: "https://example.com/b", UserID: testUserID, ExpiresAt: expiresAt},
+			{ShortURL: testShortID, OriginalURL: "https://example.com/c", UserID: testUserID},
+		}).
+		Return([]dto.URLRecord{
+			{ShortURL: testShortID}, {ShortURL: "sale"}, {ShortURL: testShortID},
+		}, nil)
+
+	shortIDs, err := s.BatchShortenURLs(ctx, testUserID, []dto.BatchRequestDTO{
+		{CorrelationID: "1", OriginalURL: "https://example.com/a", TTLSeconds: 60},
+		{CorrelationID: "2", OriginalURL: "https://example.com/b", Alias: "sale", ExpiresAt: &expiresAt},
+		{CorrelationID: "3", OriginalURL: "https://example.com/c"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testShortID, "sale", testShortID}, shortIDs)
+}
+
+func TestBatchShortenURLsInvalidExpiry(t *testing.T) {
+	s, _, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	past := time.Now().Add(-time.Hour)
+	shortIDs, err := s.BatchShortenURLs(context.Background(), testUserID, []dto.BatchRequestDTO{
+		{CorrelationID: "1", OriginalURL: "https://example.com/a"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/b", ExpiresAt: &past},
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+	var itemErr *repo.BatchItemError
+	if assert.ErrorAs(t, err, &itemErr) {
+		assert.Equal(t, 1, itemErr.Index)
+	}
+	assert.Empty(t, shortIDs)
+}
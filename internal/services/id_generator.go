@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+)
+
+// maxIDAttempts bounds the collision retries of the random and hash strategies.
+const maxIDAttempts = 5
+
+var ErrIDGenerationFailed = errors.New("could not generate a free short ID")
+
+// NewIDGenerator returns the generator selected by cfg.IDStrategy:
+//
+//   - random: random characters of the alphabet, retried on collision
+//   - counter: a base-N counter, skipping IDs already taken
+//   - hash: an HMAC of the original URL keyed with cfg.IDSalt, so every node
+//     derives the same ID for the same URL
+//   - snowflake: time, node and sequence bits, unique without storage lookups
+func NewIDGenerator(cfg *config.Config, repo repo.IURLRepository) (IDGenerator, error) {
+	alphabet := []rune(cfg.IDAlphabet)
+	switch cfg.IDStrategy {
+	case "random":
+		return &randomIDGenerator{repo: repo, alphabet: alphabet, length: cfg.IDLength}, nil
+	case "counter":
+		return &counterIDGenerator{repo: repo, alphabet: alphabet, length: cfg.IDLength}, nil
+	case "hash":
+		return &hashIDGenerator{repo: repo, alphabet: alphabet, length: cfg.IDLength, salt: []byte(cfg.IDSalt)}, nil
+	case "snowflake":
+		return newSnowflakeIDGenerator(alphabet, cfg.IDLength, int64(cfg.NodeID))
+	default:
+		return nil, fmt.Errorf("unsupported ID strategy %q", cfg.IDStrategy)
+	}
+}
+
+type randomIDGenerator struct {
+	repo     repo.IURLRepository
+	alphabet []rune
+	length   int
+}
+
+func (g *randomIDGenerator) Generate(ctx context.Context, originalURL string) (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id := make([]rune, g.length)
+		for i := range id {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("could not read random bytes: %w", err)
+			}
+			id[i] = g.alphabet[n.Int64()]
+		}
+
+		shortID := string(id)
+		taken, err := isIDTaken(ctx, g.repo, shortID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return shortID, nil
+		}
+	}
+	return "", ErrIDGenerationFailed
+}
+
+// counterIDGenerator hands out consecutive IDs. The counter lives in memory
+// and is seeded at startup by SeedIDGenerator; IDs taken since, by other
+// nodes or aliases, are still skipped one lookup at a time.
+type counterIDGenerator struct {
+	repo     repo.IURLRepository
+	alphabet []rune
+	length   int
+	next     atomic.Uint64
+}
+
+func (g *counterIDGenerator) Generate(ctx context.Context, originalURL string) (string, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		shortID := encodeID(g.next.Add(1)-1, g.alphabet, g.length)
+		taken, err := isIDTaken(ctx, g.repo, shortID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return shortID, nil
+		}
+	}
+}
+
+// SeedIDGenerator starts the counter strategy past the highest counter ID
+// already stored, reading every record once. Any stored ID of the configured
+// length made of the alphabet counts, so an alias that looks like a counter ID
+// moves the counter past it. The other strategies need no seed.
+func SeedIDGenerator(generator IDGenerator) error {
+	counter, ok := generator.(*counterIDGenerator)
+	if !ok {
+		return nil
+	}
+	return counter.seed(context.Background())
+}
+
+func (g *counterIDGenerator) seed(ctx context.Context) error {
+	var next uint64
+	it := repo.NewURLIterator(g.repo, exportPageSize)
+	for it.Next(ctx) {
+		if n, ok := decodeID(it.Record().ShortURL, g.alphabet, g.length); ok && n >= next {
+			next = n + 1
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("error seeding the ID counter: %w", err)
+	}
+	g.next.Store(next)
+	return nil
+}
+
+type hashIDGenerator struct {
+	repo     repo.IURLRepository
+	alphabet []rune
+	length   int
+	salt     []byte
+}
+
+// Generate derives the ID from the URL; on collision the attempt number is
+// mixed into the hash, which keeps the result the same on every node.
+func (g *hashIDGenerator) Generate(ctx context.Context, originalURL string) (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		mac := hmac.New(sha256.New, g.salt)
+		mac.Write([]byte(originalURL))
+		if attempt > 0 {
+			mac.Write([]byte("#" + strconv.Itoa(attempt)))
+		}
+
+		shortID := encodeDigest(mac.Sum(nil), g.alphabet, g.length)
+		taken, err := isIDTaken(ctx, g.repo, shortID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return shortID, nil
+		}
+	}
+	return "", ErrIDGenerationFailed
+}
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+)
+
+// snowflakeEpoch is the custom epoch of the 41-bit millisecond timestamp.
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// snowflakeIDGenerator packs a millisecond timestamp, the node ID and a
+// per-millisecond sequence into 63 bits. IDs are padded to the configured
+// length but may be longer; with base62 they take up to 11 characters.
+type snowflakeIDGenerator struct {
+	alphabet []rune
+	length   int
+	nodeID   int64
+
+	mu       sync.Mutex
+	lastTime int64
+	sequence int64
+	now      func() time.Time
+}
+
+func newSnowflakeIDGenerator(alphabet []rune, length int, nodeID int64) (*snowflakeIDGenerator, error) {
+	if nodeID < 0 || nodeID >= 1<<snowflakeNodeBits {
+		return nil, fmt.Errorf("snowflake node ID %d out of range", nodeID)
+	}
+	return &snowflakeIDGenerator{
+		alphabet: alphabet,
+		length:   length,
+		nodeID:   nodeID,
+		now:      time.Now,
+	}, nil
+}
+
+func (g *snowflakeIDGenerator) Generate(ctx context.Context, originalURL string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	if ms < g.lastTime {
+		// The clock moved backwards; keep issuing from the last timestamp.
+		ms = g.lastTime
+	}
+	if ms == g.lastTime {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSequence
+		if g.sequence == 0 {
+			// Sequence exhausted within this millisecond: borrow the next one.
+			ms++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.nodeID<<snowflakeSequenceBits | g.sequence
+	return encodeID(uint64(id), g.alphabet, g.length), nil
+}
+
+// isIDTaken reports whether shortID is already stored, deleted, expired or
+// not.
+func isIDTaken(ctx context.Context, urlRepo repo.IURLRepository, shortID string) (bool, error) {
+	_, _, exists, err := urlRepo.GetOriginalURL(ctx, shortID)
+	switch {
+	case errors.Is(err, repo.ErrURLDeleted), errors.Is(err, repo.ErrURLExpired):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("error checking short ID %q: %w", shortID, err)
+	}
+	return exists, nil
+}
+
+// encodeID writes n in the base of the alphabet, left-padded to length.
+func encodeID(n uint64, alphabet []rune, length int) string {
+	base := uint64(len(alphabet))
+	var digits []rune
+	for n > 0 {
+		digits = append(digits, alphabet[n%base])
+		n /= base
+	}
+	for len(digits) < length {
+		digits = append(digits, alphabet[0])
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
+}
+
+// decodeID reverses encodeID for IDs of exactly length characters. It reports
+// false for other lengths, characters outside the alphabet and overflows.
+func decodeID(shortID string, alphabet []rune, length int) (uint64, bool) {
+	digits := []rune(shortID)
+	if len(digits) != length {
+		return 0, false
+	}
+	base := uint64(len(alphabet))
+	var n uint64
+	for _, digit := range digits {
+		value := slices.Index(alphabet, digit)
+		if value < 0 || n > (math.MaxUint64-uint64(value))/base {
+			return 0, false
+		}
+		n = n*base + uint64(value)
+	}
+	return n, true
+}
+
+// encodeDigest maps the first length base-N digits of digest to the alphabet.
+func encodeDigest(digest []byte, alphabet []rune, length int) string {
+	n := new(big.Int).SetBytes(digest)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	id := make([]rune, length)
+	for i := range id {
+		n.DivMod(n, base, mod)
+		id[i] = alphabet[mod.Int64()]
+	}
+	return string(id)
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/config"
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func getTestIDConfig(strategy string) *config.Config {
+	return &config.Config{
+		IDStrategy: strategy,
+		IDLength:   8,
+		IDAlphabet: config.DefaultIDAlphabet,
+		IDSalt:     "salt",
+	}
+}
+
+func assertInAlphabet(t *testing.T, id, alphabet string) {
+	for _, r := range id {
+		assert.Contains(t, alphabet, string(r))
+	}
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		strategy      string
+		expectedError bool
+	}{
+		{strategy: "random"},
+		{strategy: "counter"},
+		{strategy: "hash"},
+		{strategy: "snowflake"},
+		{strategy: "unknown", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			generator, err := NewIDGenerator(getTestIDConfig(tt.strategy), nil)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, generator)
+		})
+	}
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	tests := []struct {
+		name          string
+		takenAttempts int
+		repoErr       error
+		expectedError error
+	}{
+		{name: "Free on first attempt"},
+		{name: "Retries on collision", takenAttempts: 2},
+		{name: "Gives up after max attempts", takenAttempts: maxIDAttempts, expectedError: ErrIDGenerationFailed},
+		{name: "Repository error", repoErr: errors.New("db error"), expectedError: errors.New("db error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockIURLRepository(ctrl)
+			ctx := context.Background()
+
+			if tt.repoErr != nil {
+				mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("", time.Time{}, false, tt.repoErr)
+			} else {
+				if tt.takenAttempts > 0 {
+					mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("https://example.com", time.Time{}, true, nil).Times(tt.takenAttempts)
+				}
+				if tt.takenAttempts < maxIDAttempts {
+					mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("", time.Time{}, false, nil)
+				}
+			}
+
+			generator, err := NewIDGenerator(getTestIDConfig("random"), mockRepo)
+			require.NoError(t, err)
+			shortID, err := generator.Generate(ctx, "https://example.com")
+
+			if tt.expectedError != nil {
+				assert.ErrorContains(t, err, tt.expectedError.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, shortID, 8)
+			assertInAlphabet(t, shortID, config.DefaultIDAlphabet)
+		})
+	}
+}
+
+func TestCounterIDGenerator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	ctx := context.Background()
+
+	// IDs left by a previous run are skipped, deleted and expired ones
+	// included.
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000000").Return("https://example.com", time.Time{}, true, nil)
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000001").Return("", time.Time{}, false, repo.ErrURLDeleted)
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000002").Return("", time.Time{}, false, repo.ErrURLExpired)
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000003").Return("", time.Time{}, false, nil)
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000004").Return("", time.Time{}, false, nil)
+
+	generator, err := NewIDGenerator(getTestIDConfig("counter"), mockRepo)
+	require.NoError(t, err)
+
+	shortID, err := generator.Generate(ctx, "https://example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, "00000003", shortID)
+
+	shortID, err = generator.Generate(ctx, "https://example.net")
+	assert.NoError(t, err)
+	assert.Equal(t, "00000004", shortID)
+}
+
+func TestSeedIDGenerator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	ctx := context.Background()
+
+	// Aliases of another length or outside the alphabet are not counter IDs.
+	mockRepo.EXPECT().ListURLs(gomock.Any(), "", exportPageSize).Return([]dto.URLRecord{
+		{ShortURL: "00000003"},
+		{ShortURL: "00000007", IsDeleted: true},
+		{ShortURL: "0000004z"},
+		{ShortURL: "spring-sale"},
+		{ShortURL: "zzzzz"},
+	}, nil)
+	mockRepo.EXPECT().GetOriginalURL(ctx, "00000050").Return("", time.Time{}, false, nil)
+
+	generator, err := NewIDGenerator(getTestIDConfig("counter"), mockRepo)
+	require.NoError(t, err)
+	require.NoError(t, SeedIDGenerator(generator))
+
+	shortID, err := generator.Generate(ctx, "https://example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, "00000050", shortID)
+
+	// The other strategies do not read the repository.
+	generator, err = NewIDGenerator(getTestIDConfig("hash"), mockRepo)
+	require.NoError(t, err)
+	assert.NoError(t, SeedIDGenerator(generator))
+}
+
+func TestSeedIDGeneratorRepositoryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+
+	dbErr := errors.New("db error")
+	mockRepo.EXPECT().ListURLs(gomock.Any(), "", exportPageSize).Return(nil, dbErr)
+
+	generator, err := NewIDGenerator(getTestIDConfig("counter"), mockRepo)
+	require.NoError(t, err)
+	assert.ErrorIs(t, SeedIDGenerator(generator), dbErr)
+}
+
+func TestHashIDGenerator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	ctx := context.Background()
+	mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("", time.Time{}, false, nil).AnyTimes()
+
+	generator, err := NewIDGenerator(getTestIDConfig("hash"), mockRepo)
+	require.NoError(t, err)
+	otherNode, err := NewIDGenerator(getTestIDConfig("hash"), mockRepo)
+	require.NoError(t, err)
+	otherSaltCfg := getTestIDConfig("hash")
+	otherSaltCfg.IDSalt = "pepper"
+	otherSalt, err := NewIDGenerator(otherSaltCfg, mockRepo)
+	require.NoError(t, err)
+
+	first, err := generator.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+	assert.Len(t, first, 8)
+	assertInAlphabet(t, first, config.DefaultIDAlphabet)
+
+	second, err := otherNode.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "the same URL and salt must give the same ID")
+
+	salted, err := otherSalt.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, salted)
+
+	other, err := generator.Generate(ctx, "https://example.org")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestHashIDGeneratorCollision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	ctx := context.Background()
+
+	generator, err := NewIDGenerator(getTestIDConfig("hash"), mockRepo)
+	require.NoError(t, err)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("https://example.org", time.Time{}, true, nil),
+		mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("", time.Time{}, false, nil),
+	)
+	shortID, err := generator.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+
+	mockRepo.EXPECT().GetOriginalURL(ctx, gomock.Any()).Return("", time.Time{}, false, nil)
+	firstChoice, err := generator.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, firstChoice, shortID)
+}
+
+func TestSnowflakeIDGenerator(t *testing.T) {
+	generator, err := newSnowflakeIDGenerator([]rune(config.DefaultIDAlphabet), 8, 7)
+	require.NoError(t, err)
+	now := snowflakeEpoch.Add(time.Hour)
+	generator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	seen := make(map[string]bool)
+	// More IDs than fit in one millisecond's sequence.
+	for i := 0; i < snowflakeMaxSequence+10; i++ {
+		shortID, err := generator.Generate(ctx, "https://example.com")
+		require.NoError(t, err)
+		require.False(t, seen[shortID], "duplicate ID %s", shortID)
+		seen[shortID] = true
+	}
+
+	// A clock going backwards must not produce duplicates either.
+	now = now.Add(-time.Second)
+	shortID, err := generator.Generate(ctx, "https://example.com")
+	require.NoError(t, err)
+	assert.False(t, seen[shortID])
+
+	_, err = newSnowflakeIDGenerator([]rune(config.DefaultIDAlphabet), 8, 1024)
+	assert.Error(t, err)
+}
+
+func TestEncodeID(t *testing.T) {
+	alphabet := []rune(config.DefaultIDAlphabet)
+	assert.Equal(t, "0000", encodeID(0, alphabet, 4))
+	assert.Equal(t, "000z", encodeID(61, alphabet, 4))
+	assert.Equal(t, "0010", encodeID(62, alphabet, 4))
+	assert.Equal(t, "10", encodeID(62, alphabet, 1))
+}
+
+func TestDecodeID(t *testing.T) {
+	alphabet := []rune(config.DefaultIDAlphabet)
+	for _, n := range []uint64{0, 61, 62, 123456789} {
+		decoded, ok := decodeID(encodeID(n, alphabet, 8), alphabet, 8)
+		assert.True(t, ok)
+		assert.Equal(t, n, decoded)
+	}
+
+	_, ok := decodeID("0000000", alphabet, 8)
+	assert.False(t, ok, "too short")
+	_, ok = decodeID("000000000", alphabet, 8)
+	assert.False(t, ok, "too long")
+	_, ok = decodeID("0000-000", alphabet, 8)
+	assert.False(t, ok, "outside the alphabet")
+	_, ok = decodeID("zzzzzzzzzzzzzzzz", alphabet, 16)
+	assert.False(t, ok, "overflow")
+}
+
+func TestShortenURLGeneratorError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+	s := NewURLService(getTestConfig(), mockRepo, NewURLDeleter(mockRepo), NewClickRecorder(mockRepo), mockIDGenerator)
+	ctx := context.Background()
+
+	mockRepo.EXPECT().GetShortIDByOriginalURL(ctx, "https://example.com").Return("", nil)
+	mockIDGenerator.EXPECT().Generate(ctx, "https://example.com").Return("", ErrIDGenerationFailed)
+
+	shortID, err := s.ShortenURL(ctx, testUserID, "https://example.com")
+	assert.ErrorIs(t, err, ErrIDGenerationFailed)
+	assert.Empty(t, shortID)
+}
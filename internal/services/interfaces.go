@@ -7,12 +7,21 @@ import (
 )
 
 type IURLService interface {
-	ConcurrentBatchDelete(ctx context.Context, shortURLs []string) error
-	BatchDeleteURLs(ctx context.Context, shortURLs []string) error
-	ShortenURL(ctx context.Context, originalURL string) (string, error)
-	ShortenAPIURL(ctx context.Context, shortenRequest *dto.ShortenRequestDTO) (string, error)
-	GetOriginalURL(ctx context.Context, shortID string) (string, bool)
-	BatchShortenURL(ctx context.Context, request dto.BatchRequestDTO) (string, error)
+	DeleteURLs(ctx context.Context, userID string, shortURLs []string) error
+	ShortenURL(ctx context.Context, userID, originalURL string) (string, error)
+	ShortenAPIURL(ctx context.Context, userID string, shortenRequest *dto.ShortenRequestDTO) (string, error)
+	GetOriginalURL(ctx context.Context, shortID string) (string, error)
+	RecordClick(shortID, referrer, userAgent, ip string)
+	BatchShortenURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]string, error)
+	ImportURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]dto.ImportResult, error)
+	GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, string, error)
+	GetURLStats(ctx context.Context, userID, shortID string, query dto.StatsQuery) (dto.LinkStatsDTO, error)
+	ExportURLs(ctx context.Context, yield func(record dto.URLRecord) error) error
 	PingDB(ctx context.Context) error
 	GetStorageType() string
 }
+
+// IDGenerator produces the short ID of a new link.
+type IDGenerator interface {
+	Generate(ctx context.Context, originalURL string) (string, error)
+}
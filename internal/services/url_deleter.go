@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	deleteQueueSize     = 1024
+	deleteBatchSize     = 100
+	deleteFlushInterval = time.Second
+	deleteFlushTimeout  = 10 * time.Second
+)
+
+var ErrDeleterStopped = errors.New("url deleter is stopped")
+
+type deleteRequest struct {
+	userID    string
+	shortURLs []string
+}
+
+// URLDeleter fans in delete requests from many callers into a single
+// buffered channel and flushes them to the repository as batched updates,
+// either when deleteBatchSize IDs are pending or every deleteFlushInterval.
+// A single request may hold more IDs than that, so every update is capped at
+// deleteBatchSize IDs, which keeps it within SQLite's bound variable limit.
+type URLDeleter struct {
+	repo          repo.IURLRepository
+	requests      chan deleteRequest
+	done          chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewURLDeleter(repo repo.IURLRepository) *URLDeleter {
+	return &URLDeleter{
+		repo:          repo,
+		requests:      make(chan deleteRequest, deleteQueueSize),
+		done:          make(chan struct{}),
+		batchSize:     deleteBatchSize,
+		flushInterval: deleteFlushInterval,
+	}
+}
+
+// Start launches the background worker. It must be called once.
+func (d *URLDeleter) Start() {
+	go d.run()
+}
+
+// Stop stops accepting requests and waits until every queued request has
+// been flushed or ctx is done.
+func (d *URLDeleter) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		close(d.requests)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue schedules the deletion of the user's short URLs. It only blocks
+// while the queue is full.
+func (d *URLDeleter) Enqueue(ctx context.Context, userID string, shortURLs []string) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		return ErrDeleterStopped
+	}
+
+	select {
+	case d.requests <- deleteRequest{userID: userID, shortURLs: shortURLs}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *URLDeleter) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.flushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[string][]string)
+	pendingCount := 0
+
+	flush := func() {
+		if pendingCount == 0 {
+			return
+		}
+		d.flush(pending)
+		pending = make(map[string][]string)
+		pendingCount = 0
+	}
+
+	for {
+		select {
+		case req, ok := <-d.requests:
+			if !ok {
+				flush()
+				return
+			}
+			pending[req.userID] = append(pending[req.userID], req.shortURLs...)
+			pendingCount += len(req.shortURLs)
+			if pendingCount >= d.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (d *URLDeleter) flush(pending map[string][]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), deleteFlushTimeout)
+	defer cancel()
+
+	for userID, shortURLs := range pending {
+		for chunk := range slices.Chunk(shortURLs, d.batchSize) {
+			if err := d.repo.BatchDeleteURLs(ctx, userID, chunk); err != nil {
+				log.Error().Err(err).Str("userID", userID).Int("count", len(chunk)).Msg("Error deleting urls")
+				continue
+			}
+			log.Info().Str("userID", userID).Int("count", len(chunk)).Msg("Deleted urls")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func setupTestDeleter(t *testing.T, batchSize int, flushInterval time.Duration) (*URLDeleter, *mocks.MockIURLRepository) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+
+	deleter := NewURLDeleter(mockRepo)
+	deleter.batchSize = batchSize
+	deleter.flushInterval = flushInterval
+	return deleter, mockRepo
+}
+
+func TestURLDeleterFlushesBySize(t *testing.T) {
+	deleter, mockRepo := setupTestDeleter(t, 3, time.Hour)
+
+	flushed := make(chan []string, 1)
+	mockRepo.EXPECT().
+		BatchDeleteURLs(gomock.Any(), "user-1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, shortURLs []string) error {
+			flushed <- shortURLs
+			return nil
+		}).
+		Times(1)
+
+	deleter.Start()
+	defer deleter.Stop(context.Background())
+
+	ctx := context.Background()
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"a", "b"}))
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"c"}))
+
+	select {
+	case shortURLs := <-flushed:
+		assert.Equal(t, []string{"a", "b", "c"}, shortURLs)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed by size")
+	}
+}
+
+func TestURLDeleterSplitsLargeRequests(t *testing.T) {
+	deleter, mockRepo := setupTestDeleter(t, 3, time.Hour)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().BatchDeleteURLs(gomock.Any(), "user-1", []string{"a", "b", "c"}).Return(nil),
+		mockRepo.EXPECT().BatchDeleteURLs(gomock.Any(), "user-1", []string{"d", "e", "f"}).Return(errors.New("db error")),
+		mockRepo.EXPECT().BatchDeleteURLs(gomock.Any(), "user-1", []string{"g"}).Return(nil),
+	)
+
+	deleter.Start()
+
+	ctx := context.Background()
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"a", "b", "c", "d", "e", "f", "g"}))
+	assert.NoError(t, deleter.Stop(ctx))
+}
+
+func TestURLDeleterFlushesByTime(t *testing.T) {
+	deleter, mockRepo := setupTestDeleter(t, 100, 20*time.Millisecond)
+
+	flushed := make(chan string, 2)
+	mockRepo.EXPECT().
+		BatchDeleteURLs(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, userID string, _ []string) error {
+			flushed <- userID
+			return nil
+		}).
+		Times(2)
+
+	deleter.Start()
+	defer deleter.Stop(context.Background())
+
+	ctx := context.Background()
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"a"}))
+	assert.NoError(t, deleter.Enqueue(ctx, "user-2", []string{"b"}))
+
+	users := make(map[string]bool)
+	for range 2 {
+		select {
+		case userID := <-flushed:
+			users[userID] = true
+		case <-time.After(time.Second):
+			t.Fatal("batch was not flushed by time")
+		}
+	}
+	assert.Equal(t, map[string]bool{"user-1": true, "user-2": true}, users)
+}
+
+func TestURLDeleterFlushesOnStop(t *testing.T) {
+	deleter, mockRepo := setupTestDeleter(t, 100, time.Hour)
+
+	mockRepo.EXPECT().
+		BatchDeleteURLs(gomock.Any(), "user-1", []string{"a", "b"}).
+		Return(errors.New("db error")).
+		Times(1)
+
+	deleter.Start()
+
+	ctx := context.Background()
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"a"}))
+	assert.NoError(t, deleter.Enqueue(ctx, "user-1", []string{"b"}))
+	assert.NoError(t, deleter.Stop(ctx))
+
+	assert.ErrorIs(t, deleter.Enqueue(ctx, "user-1", []string{"c"}), ErrDeleterStopped)
+}
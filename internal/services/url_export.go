@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+)
+
+// exportPageSize is the number of records read from the repository at once.
+// In-process backends scan every record for each page, so pages are large.
+const exportPageSize = 5000
+
+// ExportURLs calls yield for every stored link, deleted ones included, in
+// short ID order. Records are read a page at a time, so the export never
+// holds more than a page in memory. An error from yield stops the export
+// and is returned as is.
+func (s *URLService) ExportURLs(ctx context.Context, yield func(record dto.URLRecord) error) error {
+	it := repo.NewURLIterator(s.repo, exportPageSize)
+	for it.Next(ctx) {
+		if err := yield(it.Record()); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("error listing urls: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestExportURLs(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	firstPage := make([]dto.URLRecord, exportPageSize)
+	for i := range firstPage {
+		firstPage[i] = dto.URLRecord{ShortURL: "a" + string(rune('0'+i%10))}
+	}
+	firstPage[exportPageSize-1].ShortURL = "m"
+	gomock.InOrder(
+		mockRepo.EXPECT().ListURLs(ctx, "", exportPageSize).Return(firstPage, nil),
+		mockRepo.EXPECT().ListURLs(ctx, "m", exportPageSize).Return([]dto.URLRecord{{ShortURL: "z"}}, nil),
+	)
+
+	var exported []string
+	err := s.ExportURLs(ctx, func(record dto.URLRecord) error {
+		exported = append(exported, record.ShortURL)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, exported, exportPageSize+1)
+	assert.Equal(t, "z", exported[exportPageSize])
+}
+
+func TestExportURLsErrors(t *testing.T) {
+	t.Run("Repository error", func(t *testing.T) {
+		s, mockRepo, ctrl := setupTestService(t)
+		defer ctrl.Finish()
+
+		dbErr := errors.New("db error")
+		mockRepo.EXPECT().ListURLs(gomock.Any(), "", exportPageSize).Return(nil, dbErr)
+
+		err := s.ExportURLs(context.Background(), func(record dto.URLRecord) error { return nil })
+		assert.ErrorIs(t, err, dbErr)
+	})
+
+	t.Run("Yield error stops the export", func(t *testing.T) {
+		s, mockRepo, ctrl := setupTestService(t)
+		defer ctrl.Finish()
+
+		writeErr := errors.New("client went away")
+		mockRepo.EXPECT().
+			ListURLs(gomock.Any(), "", exportPageSize).
+			Return([]dto.URLRecord{{ShortURL: "a"}, {ShortURL: "b"}}, nil)
+
+		var calls int
+		err := s.ExportURLs(context.Background(), func(record dto.URLRecord) error {
+			calls++
+			return writeErr
+		})
+		assert.Equal(t, writeErr, err)
+		assert.Equal(t, 1, calls)
+	})
+}
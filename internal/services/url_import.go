@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+)
+
+// ImportURLs stores a chunk of an import. Unlike BatchShortenURLs, a request
+// that fails does not fail the chunk: its error is reported in its result and
+// the other requests are still stored. The returned error is only set when
+// the chunk could not be stored at all.
+func (s *URLService) ImportURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]dto.ImportResult, error) {
+	results := make([]dto.ImportResult, len(requests))
+	records := make([]dto.URLRecord, 0, len(requests))
+	// indexes maps each record back to its request.
+	indexes := make([]int, 0, len(requests))
+
+	now := s.now()
+	for i, request := range requests {
+		originalURL, err := NormalizeURL(request.OriginalURL)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		expiresAt, err := expiryTime(request.ExpiresAt, request.TTLSeconds, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		request.OriginalURL = originalURL
+
+		shortID, err := s.importShortID(ctx, request)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		records = append(records, dto.URLRecord{
+			ShortURL:    shortID,
+			OriginalURL: originalURL,
+			UserID:      userID,
+			ExpiresAt:   expiresAt,
+		})
+		indexes = append(indexes, i)
+	}
+
+	// Every failed attempt either drops a request or draws a new ID for it,
+	// so the loop ends once each request has run out of attempts.
+	attempts := make([]int, len(requests))
+	for len(records) > 0 {
+		saved, err := s.repo.SaveURLBatch(ctx, records)
+		if err == nil {
+			for j, record := range saved {
+				results[indexes[j]].ShortID = record.ShortURL
+			}
+			break
+		}
+
+		var itemErr *repo.BatchItemError
+		if !errors.As(err, &itemErr) || itemErr.Index >= len(records) {
+			return nil, fmt.Errorf("failed to save import chunk: %w", err)
+		}
+		j, i := itemErr.Index, indexes[itemErr.Index]
+
+		attempts[i]++
+		if errors.Is(err, repo.ErrShortIDTaken) && requests[i].Alias == "" && attempts[i] < maxIDAttempts {
+			shortID, genErr := s.idGenerator.Generate(ctx, records[j].OriginalURL)
+			if genErr == nil {
+				records[j].ShortURL = shortID
+				continue
+			}
+			err = fmt.Errorf("error generating short ID: %w", genErr)
+		} else if errors.Is(err, repo.ErrShortIDTaken) {
+			err = fmt.Errorf("short ID %q: %w", records[j].ShortURL, repo.ErrShortIDTaken)
+		}
+
+		results[i].Err = err
+		records = append(records[:j], records[j+1:]...)
+		indexes = append(indexes[:j], indexes[j+1:]...)
+	}
+	return results, nil
+}
+
+func (s *URLService) importShortID(ctx context.Context, request dto.BatchRequestDTO) (string, error) {
+	if request.Alias != "" {
+		if err := ValidateAlias(request.Alias); err != nil {
+			return "", err
+		}
+		return request.Alias, nil
+	}
+
+	shortID, err := s.idGenerator.Generate(ctx, request.OriginalURL)
+	if err != nil {
+		return "", fmt.Errorf("error generating short ID: %w", err)
+	}
+	return shortID, nil
+}
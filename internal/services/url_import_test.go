@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestImportURLs(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	requests := []dto.BatchRequestDTO{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2"},
+		{CorrelationID: "3", OriginalURL: "https://example.org", Alias: "api"},
+		{CorrelationID: "4", OriginalURL: "https://example.net", Alias: "spring-sale"},
+		{CorrelationID: "5", OriginalURL: "javascript:alert(1)"},
+	}
+	generated := dto.URLRecord{ShortURL: testShortID, OriginalURL: "https://example.com", UserID: testUserID}
+	aliased := dto.URLRecord{ShortURL: "spring-sale", OriginalURL: "https://example.net", UserID: testUserID}
+
+	gomock.InOrder(
+		mockRepo.EXPECT().
+			SaveURLBatch(ctx, []dto.URLRecord{generated, aliased}).
+			Return(nil, &repo.BatchItemError{Index: 1, Err: repo.ErrShortIDTaken}),
+		mockRepo.EXPECT().
+			SaveURLBatch(ctx, []dto.URLRecord{generated}).
+			Return([]dto.URLRecord{generated}, nil),
+	)
+
+	results, err := s.ImportURLs(ctx, testUserID, requests)
+	require.NoError(t, err)
+	require.Len(t, results, len(requests))
+
+	assert.Equal(t, testShortID, results[0].ShortID)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrEmptyURL)
+	assert.ErrorIs(t, results[2].Err, ErrInvalidAlias)
+	assert.ErrorIs(t, results[3].Err, repo.ErrShortIDTaken)
+	assert.Empty(t, results[3].ShortID)
+	assert.ErrorIs(t, results[4].Err, ErrInvalidURL)
+}
+
+func TestImportURLsRetriesGeneratedIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+	s := NewURLService(getTestConfig(), mockRepo, NewURLDeleter(mockRepo), NewClickRecorder(mockRepo), mockIDGenerator).(*URLService)
+
+	gomock.InOrder(
+		mockIDGenerator.EXPECT().Generate(ctx, "https://example.com").Return("first111", nil),
+		mockRepo.EXPECT().
+			SaveURLBatch(ctx, gomock.Any()).
+			Return(nil, &repo.BatchItemError{Index: 0, Err: repo.ErrShortIDTaken}),
+		mockIDGenerator.EXPECT().Generate(ctx, "https://example.com").Return("second22", nil),
+		mockRepo.EXPECT().
+			SaveURLBatch(ctx, []dto.URLRecord{{ShortURL: "second22", OriginalURL: "https://example.com", UserID: testUserID}}).
+			DoAndReturn(func(_ context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+				return records, nil
+			}),
+	)
+
+	results, err := s.ImportURLs(ctx, testUserID, []dto.BatchRequestDTO{{OriginalURL: "https://example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, "second22", results[0].ShortID)
+}
+
+func TestImportURLsRepositoryError(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	dbErr := errors.New("db error")
+	mockRepo.EXPECT().SaveURLBatch(ctx, gomock.Any()).Return(nil, dbErr)
+
+	results, err := s.ImportURLs(ctx, testUserID, []dto.BatchRequestDTO{{OriginalURL: "https://example.com"}})
+	assert.ErrorIs(t, err, dbErr)
+	assert.Nil(t, results)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	expireInterval  = time.Minute
+	expireBatchSize = 1000
+	expireTimeout   = 30 * time.Second
+)
+
+// URLJanitor tombstones expired links in the background: on start, then
+// every expireInterval. Expired links already answer 410 before the janitor
+// gets to them; tombstoning them takes them out of the owners' lists. Each
+// pass works in batches of expireBatchSize until no expired link is left, so
+// that a large backlog never becomes one long write.
+type URLJanitor struct {
+	repo      repo.IURLRepository
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewURLJanitor(repo repo.IURLRepository) *URLJanitor {
+	return &URLJanitor{
+		repo:      repo,
+		interval:  expireInterval,
+		batchSize: expireBatchSize,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the background worker. It must be called once.
+func (j *URLJanitor) Start() {
+	go j.run()
+}
+
+// Stop stops the worker, waiting for the current batch until ctx is done.
+func (j *URLJanitor) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *URLJanitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.expire()
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire runs one pass and returns the number of links it tombstoned.
+func (j *URLJanitor) expire() int {
+	ctx, cancel := context.WithTimeout(context.Background(), expireTimeout)
+	defer cancel()
+
+	now := j.now()
+	total := 0
+	for {
+		n, err := j.repo.ExpireURLs(ctx, now, j.batchSize)
+		total += n
+		if err != nil {
+			log.Error().Err(err).Int("count", total).Msg("Error expiring urls")
+			return total
+		}
+		if n < j.batchSize {
+			break
+		}
+
+		select {
+		case <-j.stop:
+			return total
+		default:
+		}
+	}
+
+	if total > 0 {
+		log.Info().Int("count", total).Msg("Expired urls")
+	}
+	return total
+}
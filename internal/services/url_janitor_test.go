@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func setupTestJanitor(t *testing.T, batchSize int) (*URLJanitor, *mocks.MockIURLRepository, time.Time) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockIURLRepository(ctrl)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	janitor := NewURLJanitor(mockRepo)
+	janitor.batchSize = batchSize
+	janitor.now = func() time.Time { return now }
+	return janitor, mockRepo, now
+}
+
+func TestURLJanitorExpiresInBatches(t *testing.T) {
+	janitor, mockRepo, now := setupTestJanitor(t, 2)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().ExpireURLs(gomock.Any(), now, 2).Return(2, nil),
+		mockRepo.EXPECT().ExpireURLs(gomock.Any(), now, 2).Return(2, nil),
+		mockRepo.EXPECT().ExpireURLs(gomock.Any(), now, 2).Return(1, nil),
+	)
+
+	assert.Equal(t, 5, janitor.expire())
+}
+
+func TestURLJanitorStopsOnError(t *testing.T) {
+	janitor, mockRepo, now := setupTestJanitor(t, 2)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().ExpireURLs(gomock.Any(), now, 2).Return(2, nil),
+		mockRepo.EXPECT().ExpireURLs(gomock.Any(), now, 2).Return(0, errors.New("db error")),
+	)
+
+	assert.Equal(t, 2, janitor.expire())
+}
+
+func TestURLJanitorRunsOnStart(t *testing.T) {
+	janitor, mockRepo, now := setupTestJanitor(t, 2)
+	janitor.interval = time.Hour
+
+	expired := make(chan struct{})
+	mockRepo.EXPECT().
+		ExpireURLs(gomock.Any(), now, 2).
+		DoAndReturn(func(context.Context, time.Time, int) (int, error) {
+			close(expired)
+			return 0, nil
+		}).
+		Times(1)
+
+	janitor.Start()
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not run on start")
+	}
+	assert.NoError(t, janitor.Stop(context.Background()))
+}
+
+func TestURLJanitorStopsBetweenBatches(t *testing.T) {
+	janitor, mockRepo, now := setupTestJanitor(t, 2)
+	janitor.interval = time.Hour
+
+	// A full batch would be followed by another one, but Stop comes first.
+	mockRepo.EXPECT().
+		ExpireURLs(gomock.Any(), now, 2).
+		DoAndReturn(func(context.Context, time.Time, int) (int, error) {
+			go janitor.Stop(context.Background())
+			<-janitor.stop
+			return 2, nil
+		}).
+		Times(1)
+
+	janitor.Start()
+	select {
+	case <-janitor.done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop")
+	}
+}
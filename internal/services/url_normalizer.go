@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrEmptyURL is reported, wrapped in ErrInvalidURL, for an empty URL.
+	ErrEmptyURL = errors.New("URL is empty")
+)
+
+// maxURLLength bounds the URLs accepted for shortening.
+const maxURLLength = 8192
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL validates a URL to shorten and returns its canonical form, so
+// that equivalent spellings of a URL are stored once:
+//
+//   - only absolute http and https URLs without credentials are accepted;
+//   - the scheme and host are lowercased, internationalized hosts are
+//     converted to Punycode and the default port is dropped;
+//   - dot segments and trailing slashes are removed from the path, so that
+//     https://example.com/ becomes https://example.com;
+//   - percent-encoding uses uppercase hex digits and unreserved characters
+//     are decoded;
+//   - empty query parameters are dropped and the rest sorted by key.
+//
+// Invalid URLs are reported with an error wrapping ErrInvalidURL that gives
+// the reason.
+func NormalizeURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, ErrEmptyURL)
+	}
+	if len(rawURL) > maxURLLength {
+		return "", invalidURL("it is longer than %d bytes", maxURLLength)
+	}
+	if strings.IndexFunc(rawURL, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", invalidURL("it contains whitespace or control characters")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", invalidURL("%v", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" {
+		return "", invalidURL("it must start with http:// or https://")
+	}
+	if _, ok := defaultPorts[scheme]; !ok {
+		return "", invalidURL("scheme %q is not allowed, use http or https", u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", invalidURL("it has no host")
+	}
+	if u.User != nil {
+		return "", invalidURL("it must not contain credentials")
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", invalidURL("port %q is out of range", port)
+		}
+		if port = strconv.Itoa(n); port != defaultPorts[scheme] {
+			host += ":" + port
+		}
+	}
+
+	path, err := canonicalEscapes(u.EscapedPath(), isPathChar)
+	if err != nil {
+		return "", err
+	}
+	// The root path is left empty, like every trailing slash.
+	path = strings.TrimRight(removeDotSegments(path), "/")
+
+	query, err := normalizeQuery(u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	normalized := scheme + "://" + host + path
+	if query != "" {
+		normalized += "?" + query
+	}
+	if fragment := u.EscapedFragment(); fragment != "" {
+		normalized += "#" + fragment
+	}
+	return normalized, nil
+}
+
+func invalidURL(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidURL, fmt.Sprintf(format, args...))
+}
+
+// normalizeHost lowercases a host name and converts its internationalized
+// labels to Punycode, as IDNA lookups do. IP addresses are returned in their
+// canonical form.
+func normalizeHost(host string) (string, error) {
+	if strings.Contains(host, ":") {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return "", invalidURL("host %q is not a valid IPv6 address", host)
+		}
+		return "[" + ip.String() + "]", nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", invalidURL("host %q: %v", host, err)
+	}
+	host = strings.TrimSuffix(ascii, ".")
+	if host == "" {
+		return "", invalidURL("it has no host")
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" {
+			return "", invalidURL("host %q has an empty label", host)
+		}
+		if len(label) > 63 {
+			return "", invalidURL("host label %q is longer than 63 characters", label)
+		}
+	}
+	if len(host) > 253 {
+		return "", invalidURL("host is longer than 253 characters")
+	}
+	return host, nil
+}
+
+// removeDotSegments resolves the "." and ".." segments of an absolute path,
+// RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return path
+	}
+
+	segments := strings.Split(path, "/")[1:]
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		if last {
+			out = append(out, "")
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+// normalizeQuery drops the empty parameters of a raw query, canonicalizes
+// their encoding and sorts them by key, keeping the order of repeated keys.
+func normalizeQuery(rawQuery string) (string, error) {
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		param, err := canonicalEscapes(param, isQueryChar)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, param)
+	}
+
+	key := func(param string) string {
+		key, _, _ := strings.Cut(param, "=")
+		return key
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return key(params[i]) < key(params[j])
+	})
+	return strings.Join(params, "&"), nil
+}
+
+// canonicalEscapes decodes the percent-encoded unreserved characters of s,
+// uppercases the other escapes and percent-encodes the bytes that allowed
+// does not accept.
+func canonicalEscapes(s string, allowed func(c byte) bool) (string, error) {
+	const hex = "0123456789ABCDEF"
+
+	var out strings.Builder
+	out.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", invalidURL("invalid percent-encoding %q", s[i:min(i+3, len(s))])
+			}
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				out.WriteByte(decoded)
+			} else {
+				out.WriteByte('%')
+				out.WriteByte(hex[decoded>>4])
+				out.WriteByte(hex[decoded&0x0f])
+			}
+			i += 2
+		case allowed(c):
+			out.WriteByte(c)
+		default:
+			out.WriteByte('%')
+			out.WriteByte(hex[c>>4])
+			out.WriteByte(hex[c&0x0f])
+		}
+	}
+	return out.String(), nil
+}
+
+func isUnreserved(c byte) bool {
+	return isAlphaNum(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isPathChar reports whether c may appear unescaped in a path, RFC 3986
+// section 3.3.
+func isPathChar(c byte) bool {
+	return isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/", c) >= 0
+}
+
+// isQueryChar reports whether c may appear unescaped in a query parameter,
+// RFC 3986 section 3.4.
+func isQueryChar(c byte) bool {
+	return isPathChar(c) || c == '?'
+}
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -2,129 +2,252 @@ package services
 
 import (
 	"context"
-	"crypto/sha256"
-	"database/sql"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/VladimirAzanza/url-shortener/config"
 	"github.com/VladimirAzanza/url-shortener/internal/dto"
 	"github.com/VladimirAzanza/url-shortener/internal/repo"
-	"github.com/rs/zerolog/log"
 )
 
+const (
+	DefaultUserURLsLimit = 100
+	MaxUserURLsLimit     = 1000
+)
+
+// ErrLinkNotFound is returned when a short URL does not exist.
+var ErrLinkNotFound = errors.New("link not found")
+
 type URLService struct {
-	cfg  *config.Config
-	repo repo.IURLRepository
+	cfg         *config.Config
+	repo        repo.IURLRepository
+	deleter     *URLDeleter
+	clicks      *ClickRecorder
+	idGenerator IDGenerator
+	// now is the clock expiry and click times are computed from.
+	now func() time.Time
 }
 
-func NewURLService(cfg *config.Config, repo repo.IURLRepository) IURLService {
+func NewURLService(
+	cfg *config.Config, repo repo.IURLRepository, deleter *URLDeleter, clicks *ClickRecorder, idGenerator IDGenerator,
+) IURLService {
 	return &URLService{
-		cfg:  cfg,
-		repo: repo,
+		cfg:         cfg,
+		repo:        repo,
+		deleter:     deleter,
+		clicks:      clicks,
+		idGenerator: idGenerator,
+		now:         time.Now,
+	}
+}
+
+// DeleteURLs queues the user's short URLs for asynchronous deletion.
+func (s *URLService) DeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
+	if err := s.deleter.Enqueue(ctx, userID, shortURLs); err != nil {
+		return fmt.Errorf("error at queueing urls for deletion: %w", err)
+	}
+	return nil
+}
+
+// RecordClick queues a click event for a redirect of shortID, with the
+// visitor's IP address hashed. It never blocks the redirect: when the click
+// queue is full the event is dropped.
+func (s *URLService) RecordClick(shortID, referrer, userAgent, ip string) {
+	s.clicks.Record(dto.ClickEvent{
+		ShortURL:  shortID,
+		ClickedAt: s.now().UTC(),
+		Referrer:  truncateHeader(referrer),
+		UserAgent: truncateHeader(userAgent),
+		IPHash:    hashIP(s.cfg.SecretKey, ip),
+	})
+}
+
+// ShortenURL stores originalURL, normalized by NormalizeURL, under a new
+// short ID. An invalid URL is reported with an error wrapping ErrInvalidURL
+// and a URL that is already stored with a *repo.ErrURLConflict carrying its
+// short ID.
+func (s *URLService) ShortenURL(ctx context.Context, userID, originalURL string) (string, error) {
+	return s.shorten(ctx, userID, originalURL, "", time.Time{})
+}
+
+// ShortenAPIURL shortens the requested URL, under its alias when one is set,
+// and with its expiry when one is set. An invalid expiry is reported with an
+// error wrapping ErrInvalidExpiry, a taken alias with an error wrapping
+// repo.ErrShortIDTaken and an already stored URL with a *repo.ErrURLConflict.
+func (s *URLService) ShortenAPIURL(ctx context.Context, userID string, shortenRequest *dto.ShortenRequestDTO) (string, error) {
+	expiresAt, err := expiryTime(shortenRequest.ExpiresAt, shortenRequest.TTLSeconds, s.now())
+	if err != nil {
+		return "", err
 	}
+	return s.shorten(ctx, userID, shortenRequest.URL, shortenRequest.Alias, expiresAt)
 }
 
-func (s *URLService) ConcurrentBatchDelete(ctx context.Context, shortURLs []string) error {
-	const batchSize = 100
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(shortURLs)/batchSize+1)
-
-	for i := 0; i < len(shortURLs); i += batchSize {
-		wg.Add(1)
-		go func(start int) {
-			defer wg.Done()
-			end := start + batchSize
-			if end > len(shortURLs) {
-				end = len(shortURLs)
+// GetOriginalURL resolves a short ID. A short ID that does not exist is
+// reported with ErrLinkNotFound, an expired link with an error wrapping
+// repo.ErrURLExpired and a deleted one with an error wrapping
+// repo.ErrURLDeleted. The lookup is only bounded by ctx: when ctx ends
+// first, the error wraps ctx.Err().
+func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
+	originalURL, _, exists, err := s.repo.GetOriginalURL(ctx, shortID)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Drivers do not always wrap the context's error in the one of an
+		// interrupted query.
+		return "", fmt.Errorf("error getting original URL: %w: %w", ctx.Err(), err)
+	case err != nil:
+		return "", fmt.Errorf("error getting original URL: %w", err)
+	case !exists:
+		return "", ErrLinkNotFound
+	}
+	return originalURL, nil
+}
+
+// BatchShortenURLs shortens every request in a single all-or-nothing write
+// and returns the short IDs in request order. Unlike ShortenURL, an already
+// shortened URL is not an error: its existing short ID is returned. Errors
+// caused by one request are reported with a *repo.BatchItemError. A generated
+// ID taken by a concurrent request is replaced, up to maxIDAttempts times.
+func (s *URLService) BatchShortenURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]string, error) {
+	now := s.now()
+	records := make([]dto.URLRecord, 0, len(requests))
+	for i, request := range requests {
+		originalURL, err := NormalizeURL(request.OriginalURL)
+		if err != nil {
+			return nil, &repo.BatchItemError{Index: i, Err: err}
+		}
+		expiresAt, err := expiryTime(request.ExpiresAt, request.TTLSeconds, now)
+		if err != nil {
+			return nil, &repo.BatchItemError{Index: i, Err: err}
+		}
+
+		shortID := request.Alias
+		if shortID != "" {
+			if err := ValidateAlias(shortID); err != nil {
+				return nil, &repo.BatchItemError{Index: i, Err: err}
 			}
-			batch := shortURLs[start:end]
-			if err := s.repo.BatchDeleteURLs(ctx, batch); err != nil {
-				errChan <- err
+		} else {
+			shortID, err = s.idGenerator.Generate(ctx, originalURL)
+			if err != nil {
+				return nil, &repo.BatchItemError{Index: i, Err: fmt.Errorf("error generating short ID: %w", err)}
 			}
-		}(i)
+		}
+
+		records = append(records, dto.URLRecord{
+			ShortURL:    shortID,
+			OriginalURL: originalURL,
+			UserID:      userID,
+			ExpiresAt:   expiresAt,
+		})
 	}
 
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
+	attempts := make([]int, len(records))
+	for {
+		saved, err := s.repo.SaveURLBatch(ctx, records)
+		if err == nil {
+			shortIDs := make([]string, 0, len(saved))
+			for _, record := range saved {
+				shortIDs = append(shortIDs, record.ShortURL)
+			}
+			return shortIDs, nil
+		}
 
-	for err := range errChan {
+		var itemErr *repo.BatchItemError
+		if !errors.As(err, &itemErr) || itemErr.Index >= len(records) ||
+			!errors.Is(err, repo.ErrShortIDTaken) || requests[itemErr.Index].Alias != "" {
+			return nil, fmt.Errorf("failed to save batch: %w", err)
+		}
+
+		// Another request took the generated ID after it was checked.
+		i := itemErr.Index
+		attempts[i]++
+		if attempts[i] >= maxIDAttempts {
+			return nil, &repo.BatchItemError{Index: i, Err: fmt.Errorf("error generating short ID: %w", ErrIDGenerationFailed)}
+		}
+		shortID, err := s.idGenerator.Generate(ctx, records[i].OriginalURL)
 		if err != nil {
-			return err
+			return nil, &repo.BatchItemError{Index: i, Err: fmt.Errorf("error generating short ID: %w", err)}
 		}
+		records[i].ShortURL = shortID
 	}
-
-	return nil
 }
 
-func (s *URLService) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
-	if err := s.repo.BatchDeleteURLs(ctx, shortURLs); err != nil {
-		return fmt.Errorf("error at deleting urls: %w", err)
+// shorten saves originalURL under the alias when set, or under a generated ID,
+// expiring at expiresAt unless it is zero.
+// A URL that is already stored is reported with a *repo.ErrURLConflict
+// carrying its existing short ID, whatever the alias. Only a taken alias is
+// reported with repo.ErrShortIDTaken: a generated ID taken by a concurrent
+// request is replaced, up to maxIDAttempts times.
+func (s *URLService) shorten(ctx context.Context, userID, rawURL, alias string, expiresAt time.Time) (string, error) {
+	originalURL, err := NormalizeURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if alias != "" {
+		if err := ValidateAlias(alias); err != nil {
+			return "", err
+		}
 	}
-	return nil
-}
 
-func (s *URLService) ShortenURL(ctx context.Context, originalURL string) (string, error) {
 	existingShortID, err := s.repo.GetShortIDByOriginalURL(ctx, originalURL)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	if err != nil {
 		return "", fmt.Errorf("error checking existing URL: %w", err)
 	}
 
 	if existingShortID != "" {
-		return existingShortID, nil
+		return "", &repo.ErrURLConflict{ShortID: existingShortID}
 	}
 
-	shortID := generateUniqueID(originalURL)
-	if err := s.repo.SaveShortID(ctx, shortID, originalURL); err != nil {
-		fmt.Printf("Error saving URL: %v\n", err)
-		return "", err
-	}
-	return shortID, nil
-}
-
-func (s *URLService) ShortenAPIURL(ctx context.Context, shortenRequest *dto.ShortenRequestDTO) (string, error) {
-	return s.ShortenURL(ctx, shortenRequest.URL)
-}
-
-func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, bool) {
-	timer := time.NewTimer(100 * time.Millisecond)
-	defer timer.Stop()
+	for attempt := 1; ; attempt++ {
+		shortID := alias
+		if shortID == "" {
+			shortID, err = s.idGenerator.Generate(ctx, originalURL)
+			if err != nil {
+				return "", fmt.Errorf("error generating short ID: %w", err)
+			}
+		}
 
-	select {
-	case <-timer.C:
-		originalURL, exists, err := s.repo.GetOriginalURL(ctx, shortID)
-		if err != nil {
-			log.Error().Err(err).Msg("Error getting original URL")
-			return "", false
+		err := s.repo.SaveShortID(ctx, userID, shortID, originalURL, expiresAt)
+		var conflict *repo.ErrURLConflict
+		switch {
+		case err == nil:
+			return shortID, nil
+		case errors.As(err, &conflict):
+			// Another request stored the same URL after the check above.
+			return "", err
+		case errors.Is(err, repo.ErrShortIDTaken) && alias != "":
+			return "", fmt.Errorf("short ID %q: %w", shortID, err)
+		case errors.Is(err, repo.ErrShortIDTaken) && attempt < maxIDAttempts:
+			// Another request took the generated ID after it was checked.
+			continue
+		case errors.Is(err, repo.ErrShortIDTaken):
+			return "", fmt.Errorf("error generating short ID: %w", ErrIDGenerationFailed)
 		}
-		return originalURL, exists
-	case <-ctx.Done():
-		return "", false
+		return "", fmt.Errorf("failed to save URL %s: %w", originalURL, err)
 	}
 }
 
-func (s *URLService) BatchShortenURL(ctx context.Context, request dto.BatchRequestDTO) (string, error) {
-	existingShortID, err := s.repo.GetShortIDByOriginalURL(ctx, request.OriginalURL)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return "", fmt.Errorf("error checking existing URL: %w", err)
+// GetUserURLs returns a page of the user's links that sort after cursor,
+// along with the cursor of the next page (empty on the last page).
+func (s *URLService) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, string, error) {
+	if limit <= 0 {
+		limit = DefaultUserURLsLimit
 	}
-
-	if existingShortID != "" {
-		return existingShortID, nil
+	if limit > MaxUserURLsLimit {
+		limit = MaxUserURLsLimit
 	}
 
-	shortID := generateUniqueID(request.OriginalURL)
-	err = s.repo.SaveBatchURL(ctx, shortID, request.OriginalURL)
+	records, err := s.repo.GetUserURLs(ctx, userID, cursor, limit+1)
 	if err != nil {
-		return "", fmt.Errorf("failed to save URL %s: %w", request.OriginalURL, err)
+		return nil, "", fmt.Errorf("error getting user urls: %w", err)
 	}
 
-	return shortID, nil
+	var nextCursor string
+	if len(records) > limit {
+		records = records[:limit]
+		nextCursor = records[limit-1].ShortURL
+	}
+	return records, nextCursor, nil
 }
 
 func (s *URLService) PingDB(ctx context.Context) error {
@@ -137,10 +260,3 @@ func (s *URLService) PingDB(ctx context.Context) error {
 func (s *URLService) GetStorageType() string {
 	return s.cfg.StorageType
 }
-
-func generateUniqueID(originalURL string) string {
-	hash := sha256.Sum256([]byte(originalURL))
-	hashStr := hex.EncodeToString(hash[:])[:8]
-	timestamp := fmt.Sprintf("%x", time.Now().UnixNano())[:8]
-	return hashStr + timestamp
-}
@@ -2,18 +2,27 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/VladimirAzanza/url-shortener/config"
 	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/VladimirAzanza/url-shortener/internal/repo"
+	"github.com/VladimirAzanza/url-shortener/internal/repo/memory"
 	"github.com/VladimirAzanza/url-shortener/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
+const (
+	testUserID  = "test-user"
+	testShortID = "Ab3dE6gH"
+)
+
 func getTestConfig() *config.Config {
 	return &config.Config{StorageType: "postgres"}
 }
@@ -23,7 +32,9 @@ func setupTestService(t *testing.T) (*URLService, *mocks.MockIURLRepository, *go
 	// defer ctrl.Finish() // This will be used at every test
 
 	mockRepo := mocks.NewMockIURLRepository(ctrl)
-	service := NewURLService(getTestConfig(), mockRepo).(*URLService)
+	mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+	mockIDGenerator.EXPECT().Generate(gomock.Any(), gomock.Any()).Return(testShortID, nil).AnyTimes()
+	service := NewURLService(getTestConfig(), mockRepo, NewURLDeleter(mockRepo), NewClickRecorder(mockRepo), mockIDGenerator).(*URLService)
 	return service, mockRepo, ctrl
 }
 
@@ -42,11 +53,11 @@ func TestShortenURL(t *testing.T) {
 			name:                "Valid URL - NEW",
 			originalURL:         "https://example.com",
 			getRepoReturnsShort: "",
-			getRepoReturnsErr:   sql.ErrNoRows,
+			getRepoReturnsErr:   nil,
 			saveRepoReturnsErr:  nil,
 			expectedError:       nil,
 			expectRepoSaveCall:  true,
-			expectedShortIDLen:  16,
+			expectedShortIDLen:  8,
 		},
 		{
 			name:                "valid URL - Exists",
@@ -54,9 +65,9 @@ func TestShortenURL(t *testing.T) {
 			getRepoReturnsShort: "existingShortID",
 			getRepoReturnsErr:   nil,
 			saveRepoReturnsErr:  nil,
-			expectedError:       nil,
+			expectedError:       &repo.ErrURLConflict{ShortID: "existingShortID"},
 			expectRepoSaveCall:  false,
-			expectedShortIDLen:  15,
+			expectedShortIDLen:  0,
 		},
 		{
 			name:                "Error at repo",
@@ -83,12 +94,12 @@ func TestShortenURL(t *testing.T) {
 
 			if tt.expectRepoSaveCall {
 				mockRepo.EXPECT().
-					SaveShortID(ctx, gomock.Any(), tt.originalURL).
+					SaveShortID(ctx, testUserID, gomock.Any(), tt.originalURL, time.Time{}).
 					Return(tt.saveRepoReturnsErr).
 					Times(1)
 			}
 
-			shortID, err := s.ShortenURL(ctx, tt.originalURL)
+			shortID, err := s.ShortenURL(ctx, testUserID, tt.originalURL)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -125,19 +136,66 @@ func TestShortenAPIURL(t *testing.T) {
 
 			mockRepo.EXPECT().
 				GetShortIDByOriginalURL(ctx, tt.originalURL).
-				Return("", sql.ErrNoRows).
+				Return("", nil).
 				Times(1)
 
 			mockRepo.EXPECT().
-				SaveShortID(ctx, gomock.Any(), tt.originalURL).
+				SaveShortID(ctx, testUserID, gomock.Any(), tt.originalURL, time.Time{}).
 				Return(nil).
 				Times(1)
 
-			shortID, err := s.ShortenAPIURL(ctx, req)
+			shortID, err := s.ShortenAPIURL(ctx, testUserID, req)
 
 			assert.NoError(t, err)
 			assert.NotEmpty(t, shortID)
-			assert.Len(t, shortID, 16)
+			assert.Equal(t, testShortID, shortID)
+		})
+	}
+}
+
+func TestShortenURLGeneratedIDTaken(t *testing.T) {
+	tests := []struct {
+		name            string
+		takenAttempts   int
+		expectedShortID string
+		expectedError   error
+	}{
+		{name: "Retries with a new ID", takenAttempts: 2, expectedShortID: "id2"},
+		{name: "Gives up after max attempts", takenAttempts: maxIDAttempts, expectedError: ErrIDGenerationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockIURLRepository(ctrl)
+			mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+			s := NewURLService(getTestConfig(), mockRepo, NewURLDeleter(mockRepo), NewClickRecorder(mockRepo), mockIDGenerator)
+			ctx := context.Background()
+			originalURL := "https://example.com"
+
+			mockRepo.EXPECT().GetShortIDByOriginalURL(ctx, originalURL).Return("", nil)
+			// Each generated ID was free when checked, but a concurrent
+			// request stored it first.
+			for i := 0; i < min(tt.takenAttempts+1, maxIDAttempts); i++ {
+				shortID := fmt.Sprintf("id%d", i)
+				var saveErr error
+				if i < tt.takenAttempts {
+					saveErr = repo.ErrShortIDTaken
+				}
+				mockIDGenerator.EXPECT().Generate(ctx, originalURL).Return(shortID, nil)
+				mockRepo.EXPECT().SaveShortID(ctx, testUserID, shortID, originalURL, time.Time{}).Return(saveErr)
+			}
+
+			shortID, err := s.ShortenURL(ctx, testUserID, originalURL)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.NotErrorIs(t, err, repo.ErrShortIDTaken)
+				assert.Empty(t, shortID)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedShortID, shortID)
 		})
 	}
 }
@@ -149,31 +207,37 @@ func TestGetOriginalURL(t *testing.T) {
 		originalURL    string
 		exists         bool
 		repoReturnsErr error
-		expectedError  bool
+		expectedError  error
 	}{
 		{
-			name:           "Existing URL",
-			shortID:        "abc123",
-			originalURL:    "https://example.com",
-			exists:         true,
-			repoReturnsErr: nil,
-			expectedError:  false,
+			name:        "Existing URL",
+			shortID:     "abc123",
+			originalURL: "https://example.com",
+			exists:      true,
 		},
 		{
-			name:           "Non-existing URL",
-			shortID:        "nonexistent",
-			originalURL:    "",
-			exists:         false,
-			repoReturnsErr: sql.ErrNoRows,
-			expectedError:  false,
+			name:          "Non-existing URL",
+			shortID:       "nonexistent",
+			exists:        false,
+			expectedError: ErrLinkNotFound,
 		},
 		{
 			name:           "Repo error",
 			shortID:        "error",
-			originalURL:    "",
 			exists:         false,
 			repoReturnsErr: errors.New("db error"),
-			expectedError:  true,
+		},
+		{
+			name:           "Deleted URL",
+			shortID:        "deleted",
+			exists:         false,
+			repoReturnsErr: repo.ErrURLDeleted,
+		},
+		{
+			name:           "Expired URL",
+			shortID:        "expired",
+			exists:         false,
+			repoReturnsErr: repo.ErrURLExpired,
 		},
 	}
 
@@ -182,67 +246,140 @@ func TestGetOriginalURL(t *testing.T) {
 			s, mockRepo, ctrl := setupTestService(t)
 			defer ctrl.Finish()
 
-			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-			defer cancel()
-
+			ctx := context.Background()
 			mockRepo.EXPECT().
 				GetOriginalURL(ctx, tt.shortID).
-				Return(tt.originalURL, tt.exists, tt.repoReturnsErr).
+				Return(tt.originalURL, time.Time{}, tt.exists, tt.repoReturnsErr).
 				Times(1)
 
-			originalURL, exists := s.GetOriginalURL(ctx, tt.shortID)
+			originalURL, err := s.GetOriginalURL(ctx, tt.shortID)
 
-			if tt.expectedError {
-				assert.False(t, exists)
+			expectedError := tt.expectedError
+			if expectedError == nil {
+				expectedError = tt.repoReturnsErr
+			}
+			if expectedError != nil {
+				assert.ErrorIs(t, err, expectedError)
 				assert.Empty(t, originalURL)
 			} else {
-				assert.Equal(t, tt.exists, exists)
-				if exists {
-					assert.Equal(t, tt.originalURL, originalURL)
-				} else {
-					assert.Empty(t, originalURL)
-				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.originalURL, originalURL)
 			}
 		})
 	}
 }
 
-func TestBatchShortenURL(t *testing.T) {
+func TestGetOriginalURLContextDone(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Like lib/pq, the repository reports the interrupted query with an error
+	// of its own.
+	driverErr := errors.New("pq: canceling statement due to user request")
+	mockRepo.EXPECT().
+		GetOriginalURL(ctx, testShortID).
+		DoAndReturn(func(ctx context.Context, shortID string) (string, time.Time, bool, error) {
+			<-ctx.Done()
+			return "", time.Time{}, false, driverErr
+		}).
+		Times(1)
+
+	originalURL, err := s.GetOriginalURL(ctx, testShortID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, driverErr)
+	assert.Empty(t, originalURL)
+}
+
+// maxGetOriginalURLP99 bounds the 99th percentile latency of a lookup in the
+// memory storage, far above its real cost, so that only a delay on the
+// redirect path can exceed it.
+const maxGetOriginalURLP99 = 5 * time.Millisecond
+
+func BenchmarkGetOriginalURL(b *testing.B) {
+	urlRepo := memory.NewMemoryRepository()
+	ctx := context.Background()
+	shortIDs := make([]string, 1000)
+	for i := range shortIDs {
+		shortIDs[i] = fmt.Sprintf("id%d", i)
+		require.NoError(b, urlRepo.SaveShortID(ctx, "", shortIDs[i], "https://example.com/"+shortIDs[i], time.Time{}))
+	}
+	s := &URLService{cfg: getTestConfig(), repo: urlRepo, now: time.Now}
+
+	latencies := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := s.GetOriginalURL(ctx, shortIDs[i%len(shortIDs)]); err != nil {
+			b.Fatal(err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	b.StopTimer()
+
+	slices.Sort(latencies)
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(p99.Nanoseconds()), "p99-ns")
+	if p99 > maxGetOriginalURLP99 {
+		b.Fatalf("p99 latency of %s is above %s", p99, maxGetOriginalURLP99)
+	}
+}
+
+func TestBatchShortenURLs(t *testing.T) {
 	tests := []struct {
-		name                string
-		originalURL         string
-		getRepoReturnsShort string
-		getRepoReturnsErr   error
-		saveRepoReturnsErr  error
-		expectedError       error
-		expectRepoSaveCall  bool
+		name             string
+		requests         []dto.BatchRequestDTO
+		expectSaveCall   bool
+		saveReturns      []dto.URLRecord
+		saveReturnsErr   error
+		expectedShortIDs []string
+		expectedError    error
+		expectedIndex    int
 	}{
 		{
-			name:                "New URL",
-			originalURL:         "https://example.com/new",
-			getRepoReturnsShort: "",
-			getRepoReturnsErr:   sql.ErrNoRows,
-			saveRepoReturnsErr:  nil,
-			expectedError:       nil,
-			expectRepoSaveCall:  true,
+			name: "New and existing URLs",
+			requests: []dto.BatchRequestDTO{
+				{CorrelationID: "1", OriginalURL: "https://example.com/new"},
+				{CorrelationID: "2", OriginalURL: "https://example.com/sale", Alias: "spring-sale"},
+				{CorrelationID: "3", OriginalURL: "https://example.com/existing"},
+			},
+			expectSaveCall: true,
+			saveReturns: []dto.URLRecord{
+				{ShortURL: testShortID, OriginalURL: "https://example.com/new", UserID: testUserID},
+				{ShortURL: "spring-sale", OriginalURL: "https://example.com/sale", UserID: testUserID},
+				{ShortURL: "existing1", OriginalURL: "https://example.com/existing", UserID: testUserID},
+			},
+			expectedShortIDs: []string{testShortID, "spring-sale", "existing1"},
 		},
 		{
-			name:                "Existing URL",
-			originalURL:         "https://example.com/existing",
-			getRepoReturnsShort: "existing123",
-			getRepoReturnsErr:   nil,
-			saveRepoReturnsErr:  nil,
-			expectedError:       nil,
-			expectRepoSaveCall:  false,
+			name: "Invalid alias fails before saving",
+			requests: []dto.BatchRequestDTO{
+				{CorrelationID: "1", OriginalURL: "https://example.com/new"},
+				{CorrelationID: "2", OriginalURL: "https://example.com/sale", Alias: "api"},
+			},
+			expectedError: ErrInvalidAlias,
+			expectedIndex: 1,
 		},
 		{
-			name:                "Repo error",
-			originalURL:         "https://example.com/error",
-			getRepoReturnsShort: "",
-			getRepoReturnsErr:   errors.New("db error"),
-			saveRepoReturnsErr:  nil,
-			expectedError:       errors.New("error checking existing URL: db error"),
-			expectRepoSaveCall:  false,
+			name: "Invalid URL fails before saving",
+			requests: []dto.BatchRequestDTO{
+				{CorrelationID: "1", OriginalURL: "https://example.com/new"},
+				{CorrelationID: "2", OriginalURL: "www.example2.com"},
+			},
+			expectedError: ErrInvalidURL,
+			expectedIndex: 1,
+		},
+		{
+			name: "Taken alias",
+			requests: []dto.BatchRequestDTO{
+				{CorrelationID: "1", OriginalURL: "https://example.com/sale", Alias: "spring-sale"},
+			},
+			expectSaveCall: true,
+			saveReturnsErr: &repo.BatchItemError{Index: 0, Err: repo.ErrShortIDTaken},
+			expectedError:  repo.ErrShortIDTaken,
+			expectedIndex:  0,
 		},
 	}
 
@@ -252,33 +389,95 @@ func TestBatchShortenURL(t *testing.T) {
 			defer ctrl.Finish()
 
 			ctx := context.Background()
-			req := dto.BatchRequestDTO{OriginalURL: tt.originalURL}
 
-			mockRepo.EXPECT().
-				GetShortIDByOriginalURL(ctx, tt.originalURL).
-				Return(tt.getRepoReturnsShort, tt.getRepoReturnsErr).
-				Times(1)
-
-			if tt.expectRepoSaveCall {
+			if tt.expectSaveCall {
+				records := make([]dto.URLRecord, 0, len(tt.requests))
+				for _, request := range tt.requests {
+					shortID := request.Alias
+					if shortID == "" {
+						shortID = testShortID
+					}
+					records = append(records, dto.URLRecord{ShortURL: shortID, OriginalURL: request.OriginalURL, UserID: testUserID})
+				}
 				mockRepo.EXPECT().
-					SaveBatchURL(ctx, gomock.Any(), tt.originalURL).
-					Return(tt.saveRepoReturnsErr).
+					SaveURLBatch(ctx, records).
+					Return(tt.saveReturns, tt.saveReturnsErr).
 					Times(1)
 			}
 
-			shortID, err := s.BatchShortenURL(ctx, req)
+			shortIDs, err := s.BatchShortenURLs(ctx, testUserID, tt.requests)
 
 			if tt.expectedError != nil {
-				assert.Error(t, err)
-				assert.Contains(t, err.Error(), tt.expectedError.Error())
-				assert.Empty(t, shortID)
-			} else {
-				assert.NoError(t, err)
-				assert.NotEmpty(t, shortID)
-				if !tt.expectRepoSaveCall {
-					assert.Equal(t, tt.getRepoReturnsShort, shortID)
+				assert.ErrorIs(t, err, tt.expectedError)
+				var itemErr *repo.BatchItemError
+				if assert.ErrorAs(t, err, &itemErr) {
+					assert.Equal(t, tt.expectedIndex, itemErr.Index)
+				}
+				assert.Empty(t, shortIDs)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedShortIDs, shortIDs)
+		})
+	}
+}
+
+func TestBatchShortenURLsGeneratedIDTaken(t *testing.T) {
+	tests := []struct {
+		name             string
+		takenAttempts    int
+		expectedShortIDs []string
+		expectedError    error
+	}{
+		{name: "Retries with a new ID", takenAttempts: 2, expectedShortIDs: []string{"id2", "spring-sale"}},
+		{name: "Gives up after max attempts", takenAttempts: maxIDAttempts, expectedError: ErrIDGenerationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockIURLRepository(ctrl)
+			mockIDGenerator := mocks.NewMockIDGenerator(ctrl)
+			s := NewURLService(getTestConfig(), mockRepo, NewURLDeleter(mockRepo), NewClickRecorder(mockRepo), mockIDGenerator)
+			ctx := context.Background()
+			requests := []dto.BatchRequestDTO{
+				{CorrelationID: "1", OriginalURL: "https://example.com/new"},
+				{CorrelationID: "2", OriginalURL: "https://example.com/sale", Alias: "spring-sale"},
+			}
+
+			// Each generated ID was free when checked, but a concurrent
+			// request stored it first.
+			var calls []any
+			for i := 0; i < min(tt.takenAttempts+1, maxIDAttempts); i++ {
+				shortID := fmt.Sprintf("id%d", i)
+				records := []dto.URLRecord{
+					{ShortURL: shortID, OriginalURL: "https://example.com/new", UserID: testUserID},
+					{ShortURL: "spring-sale", OriginalURL: "https://example.com/sale", UserID: testUserID},
+				}
+				save := mockRepo.EXPECT().SaveURLBatch(ctx, records)
+				if i < tt.takenAttempts {
+					save.Return(nil, &repo.BatchItemError{Index: 0, Err: repo.ErrShortIDTaken})
+				} else {
+					save.Return(records, nil)
+				}
+				calls = append(calls, mockIDGenerator.EXPECT().Generate(ctx, "https://example.com/new").Return(shortID, nil), save)
+			}
+			gomock.InOrder(calls...)
+
+			shortIDs, err := s.BatchShortenURLs(ctx, testUserID, requests)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.NotErrorIs(t, err, repo.ErrShortIDTaken)
+				var itemErr *repo.BatchItemError
+				if assert.ErrorAs(t, err, &itemErr) {
+					assert.Equal(t, 0, itemErr.Index)
 				}
+				assert.Empty(t, shortIDs)
+				return
 			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedShortIDs, shortIDs)
 		})
 	}
 }
@@ -332,24 +531,86 @@ func TestGetStorageType(t *testing.T) {
 	assert.Equal(t, "postgres", s.GetStorageType())
 }
 
-func TestBatchDeleteURLs(t *testing.T) {
+func TestDeleteURLs(t *testing.T) {
+	s, mockRepo, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	shortURLs := []string{"abc123", "def456"}
+
+	mockRepo.EXPECT().
+		BatchDeleteURLs(gomock.Any(), testUserID, shortURLs).
+		Return(nil).
+		Times(1)
+
+	s.deleter.Start()
+	err := s.DeleteURLs(context.Background(), testUserID, shortURLs)
+	assert.NoError(t, err)
+	assert.NoError(t, s.deleter.Stop(context.Background()))
+
+	err = s.DeleteURLs(context.Background(), testUserID, shortURLs)
+	assert.ErrorIs(t, err, ErrDeleterStopped)
+}
+
+func TestGetUserURLs(t *testing.T) {
+	records := []dto.URLRecord{
+		{ShortURL: "aaa", OriginalURL: "https://example.com/a", UserID: testUserID},
+		{ShortURL: "bbb", OriginalURL: "https://example.com/b", UserID: testUserID},
+		{ShortURL: "ccc", OriginalURL: "https://example.com/c", UserID: testUserID},
+	}
+
 	tests := []struct {
-		name          string
-		shortURLs     []string
-		repoReturns   error
-		expectedError error
+		name           string
+		cursor         string
+		limit          int
+		expectedLimit  int
+		repoReturns    []dto.URLRecord
+		repoErr        error
+		expectedLen    int
+		expectedCursor string
+		expectedError  error
 	}{
 		{
-			name:          "Successful delete",
-			shortURLs:     []string{"abc123", "def456"},
-			repoReturns:   nil,
-			expectedError: nil,
+			name:           "Last page",
+			cursor:         "",
+			limit:          5,
+			expectedLimit:  6,
+			repoReturns:    records,
+			expectedLen:    3,
+			expectedCursor: "",
+		},
+		{
+			name:           "More pages",
+			cursor:         "",
+			limit:          2,
+			expectedLimit:  3,
+			repoReturns:    records,
+			expectedLen:    2,
+			expectedCursor: "bbb",
+		},
+		{
+			name:           "Default limit",
+			cursor:         "bbb",
+			limit:          0,
+			expectedLimit:  DefaultUserURLsLimit + 1,
+			repoReturns:    records[2:],
+			expectedLen:    1,
+			expectedCursor: "",
+		},
+		{
+			name:          "Limit is capped",
+			cursor:        "",
+			limit:         MaxUserURLsLimit * 10,
+			expectedLimit: MaxUserURLsLimit + 1,
+			repoReturns:   []dto.URLRecord{},
+			expectedLen:   0,
 		},
 		{
-			name:          "Failed delete",
-			shortURLs:     []string{"abc123", "def456"},
-			repoReturns:   errors.New("delete error"),
-			expectedError: errors.New("error at deleting urls: delete error"),
+			name:          "Repo error",
+			cursor:        "",
+			limit:         5,
+			expectedLimit: 6,
+			repoErr:       errors.New("db error"),
+			expectedError: errors.New("error getting user urls: db error"),
 		},
 	}
 
@@ -361,40 +622,71 @@ func TestBatchDeleteURLs(t *testing.T) {
 			ctx := context.Background()
 
 			mockRepo.EXPECT().
-				BatchDeleteURLs(ctx, tt.shortURLs).
-				Return(tt.repoReturns).
+				GetUserURLs(ctx, testUserID, tt.cursor, tt.expectedLimit).
+				Return(tt.repoReturns, tt.repoErr).
 				Times(1)
 
-			err := s.BatchDeleteURLs(ctx, tt.shortURLs)
+			urls, nextCursor, err := s.GetUserURLs(ctx, testUserID, tt.cursor, tt.limit)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tt.expectedError.Error())
+				assert.Nil(t, urls)
 			} else {
 				assert.NoError(t, err)
+				assert.Len(t, urls, tt.expectedLen)
+				assert.Equal(t, tt.expectedCursor, nextCursor)
 			}
 		})
 	}
 }
 
-func TestConcurrentBatchDelete(t *testing.T) {
+func TestShortenAPIURLWithAlias(t *testing.T) {
 	tests := []struct {
-		name          string
-		shortURLs     []string
-		batchReturns  []error
-		expectedError error
+		name               string
+		alias              string
+		existingShortID    string
+		expectRepoGetCall  bool
+		saveRepoReturnsErr error
+		expectRepoSaveCall bool
+		expectedShortID    string
+		expectedConflict   string
+		expectedError      error
 	}{
 		{
-			name:          "Successful concurrent delete",
-			shortURLs:     make([]string, 150), // More than a batch
-			batchReturns:  []error{nil, nil},
-			expectedError: nil,
+			name:               "Free alias",
+			alias:              "spring-sale",
+			expectRepoGetCall:  true,
+			expectRepoSaveCall: true,
+			expectedShortID:    "spring-sale",
+		},
+		{
+			name:               "Taken alias",
+			alias:              "spring-sale",
+			expectRepoGetCall:  true,
+			expectRepoSaveCall: true,
+			saveRepoReturnsErr: repo.ErrShortIDTaken,
+			expectedError:      repo.ErrShortIDTaken,
+		},
+		{
+			name:              "URL already shortened",
+			alias:             "spring-sale",
+			existingShortID:   "Ab3dE6gH",
+			expectRepoGetCall: true,
+			expectedConflict:  "Ab3dE6gH",
+		},
+		{
+			name:               "URL stored concurrently",
+			alias:              "spring-sale",
+			expectRepoGetCall:  true,
+			expectRepoSaveCall: true,
+			saveRepoReturnsErr: &repo.ErrURLConflict{ShortID: "Ab3dE6gH"},
+			expectedConflict:   "Ab3dE6gH",
 		},
 		{
-			name:          "Failed concurrent delete",
-			shortURLs:     make([]string, 150),
-			batchReturns:  []error{nil, errors.New("batch error"), nil},
-			expectedError: errors.New("batch error"),
+			name:          "Reserved alias",
+			alias:         "api",
+			expectedError: ErrInvalidAlias,
 		},
 	}
 
@@ -404,25 +696,34 @@ func TestConcurrentBatchDelete(t *testing.T) {
 			defer ctrl.Finish()
 
 			ctx := context.Background()
+			originalURL := "https://example.com/sale"
 
-			mockRepo.EXPECT().
-				BatchDeleteURLs(ctx, gomock.Any()).
-				Return(tt.batchReturns[0]).
-				Times(1)
-
-			mockRepo.EXPECT().
-				BatchDeleteURLs(ctx, gomock.Any()).
-				Return(tt.batchReturns[1]).
-				Times(1)
-
-			err := s.ConcurrentBatchDelete(ctx, tt.shortURLs)
+			if tt.expectRepoGetCall {
+				mockRepo.EXPECT().
+					GetShortIDByOriginalURL(ctx, originalURL).
+					Return(tt.existingShortID, nil)
+			}
+			if tt.expectRepoSaveCall {
+				mockRepo.EXPECT().
+					SaveShortID(ctx, testUserID, tt.alias, originalURL, time.Time{}).
+					Return(tt.saveRepoReturnsErr)
+			}
 
+			shortID, err := s.ShortenAPIURL(ctx, testUserID, &dto.ShortenRequestDTO{URL: originalURL, Alias: tt.alias})
+			if tt.expectedConflict != "" {
+				var conflict *repo.ErrURLConflict
+				assert.ErrorAs(t, err, &conflict)
+				assert.Equal(t, tt.expectedConflict, conflict.ShortID)
+				assert.Empty(t, shortID)
+				return
+			}
 			if tt.expectedError != nil {
-				assert.Error(t, err)
-				assert.Equal(t, tt.expectedError, err)
-			} else {
-				assert.NoError(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Empty(t, shortID)
+				return
 			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedShortID, shortID)
 		})
 	}
 }
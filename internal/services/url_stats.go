@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+)
+
+var (
+	ErrNotLinkOwner      = errors.New("link belongs to another user")
+	ErrInvalidStatsQuery = errors.New("invalid stats query")
+)
+
+const (
+	DefaultStatsTop = 10
+	MaxStatsTop     = 100
+	// maxStatsBuckets bounds the length of a time series.
+	maxStatsBuckets = 1000
+)
+
+// defaultStatsRanges is how far back statistics go when no start is given.
+var defaultStatsRanges = map[dto.StatsBucket]time.Duration{
+	dto.StatsBucketHour: 48 * time.Hour,
+	dto.StatsBucketDay:  30 * 24 * time.Hour,
+}
+
+// GetURLStats returns the click statistics of one of the user's links. A link
+// that does not exist is reported with ErrLinkNotFound, one of another user
+// with ErrNotLinkOwner and an invalid query with an error wrapping
+// ErrInvalidStatsQuery. Unlike the repository's, the series has a point for
+// every bucket of the range, empty ones included.
+func (s *URLService) GetURLStats(
+	ctx context.Context, userID, shortID string, query dto.StatsQuery,
+) (dto.LinkStatsDTO, error) {
+	query, err := resolveStatsQuery(query, s.now())
+	if err != nil {
+		return dto.LinkStatsDTO{}, err
+	}
+
+	owner, exists, err := s.repo.GetURLOwner(ctx, shortID)
+	if err != nil {
+		return dto.LinkStatsDTO{}, fmt.Errorf("error getting link owner: %w", err)
+	}
+	if !exists {
+		return dto.LinkStatsDTO{}, ErrLinkNotFound
+	}
+	if owner == "" || owner != userID {
+		return dto.LinkStatsDTO{}, ErrNotLinkOwner
+	}
+
+	stats, err := s.repo.GetClickStats(ctx, shortID, query)
+	if err != nil {
+		return dto.LinkStatsDTO{}, fmt.Errorf("error getting click stats: %w", err)
+	}
+	stats.Series = fillSeries(stats.Series, query)
+
+	return dto.LinkStatsDTO{
+		ShortID:    shortID,
+		Bucket:     query.Bucket,
+		From:       query.From,
+		To:         query.To,
+		ClickStats: stats,
+	}, nil
+}
+
+// resolveStatsQuery applies the defaults of a statistics query, in UTC: daily
+// buckets, a range ending now and starting as far back as defaultStatsRanges,
+// and DefaultStatsTop values per top list.
+func resolveStatsQuery(query dto.StatsQuery, now time.Time) (dto.StatsQuery, error) {
+	if query.Bucket == "" {
+		query.Bucket = dto.StatsBucketDay
+	}
+	width := query.Bucket.Duration()
+	if width == 0 {
+		return query, fmt.Errorf("%w: bucket must be hour or day", ErrInvalidStatsQuery)
+	}
+
+	if query.To.IsZero() {
+		query.To = now
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultStatsRanges[query.Bucket])
+	}
+	query.From, query.To = query.From.UTC(), query.To.UTC()
+	if !query.From.Before(query.To) {
+		return query, fmt.Errorf("%w: from must be before to", ErrInvalidStatsQuery)
+	}
+	if query.To.Sub(query.From.Truncate(width)) > maxStatsBuckets*width {
+		return query, fmt.Errorf("%w: the range spans more than %d buckets", ErrInvalidStatsQuery, maxStatsBuckets)
+	}
+
+	if query.Top <= 0 {
+		query.Top = DefaultStatsTop
+	}
+	if query.Top > MaxStatsTop {
+		query.Top = MaxStatsTop
+	}
+	return query, nil
+}
+
+// fillSeries returns a point for every bucket of the query's range, taking
+// the clicks of the non-empty buckets from series.
+func fillSeries(series []dto.SeriesPoint, query dto.StatsQuery) []dto.SeriesPoint {
+	clicks := make(map[int64]int64, len(series))
+	for _, point := range series {
+		clicks[point.Start.Unix()] = point.Clicks
+	}
+
+	width := query.Bucket.Duration()
+	filled := make([]dto.SeriesPoint, 0)
+	for start := query.From.Truncate(width); start.Before(query.To); start = start.Add(width) {
+		filled = append(filled, dto.SeriesPoint{Start: start, Clicks: clicks[start.Unix()]})
+	}
+	return filled
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/url-shortener/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveStatsQuery(t *testing.T) {
+	now := time.Date(2026, 1, 31, 10, 30, 0, 0, time.UTC)
+	from := time.Date(2026, 1, 30, 0, 0, 0, 0, time.FixedZone("UTC+5", 5*60*60))
+
+	tests := []struct {
+		name          string
+		query         dto.StatsQuery
+		expected      dto.StatsQuery
+		expectedError string
+	}{
+		{
+			name:     "Defaults",
+			expected: dto.StatsQuery{Bucket: dto.StatsBucketDay, From: now.Add(-30 * 24 * time.Hour), To: now, Top: DefaultStatsTop},
+		},
+		{
+			name:     "Hourly defaults",
+			query:    dto.StatsQuery{Bucket: dto.StatsBucketHour},
+			expected: dto.StatsQuery{Bucket: dto.StatsBucketHour, From: now.Add(-48 * time.Hour), To: now, Top: DefaultStatsTop},
+		},
+		{
+			name:     "Explicit range in UTC",
+			query:    dto.StatsQuery{Bucket: dto.StatsBucketHour, From: from, Top: 3},
+			expected: dto.StatsQuery{Bucket: dto.StatsBucketHour, From: from.UTC(), To: now, Top: 3},
+		},
+		{
+			name:     "Top clamped",
+			query:    dto.StatsQuery{Top: MaxStatsTop + 1},
+			expected: dto.StatsQuery{Bucket: dto.StatsBucketDay, From: now.Add(-30 * 24 * time.Hour), To: now, Top: MaxStatsTop},
+		},
+		{
+			name:     "Longest range",
+			query:    dto.StatsQuery{Bucket: dto.StatsBucketHour, From: now.Truncate(time.Hour).Add(-(maxStatsBuckets - 1) * time.Hour)},
+			expected: dto.StatsQuery{Bucket: dto.StatsBucketHour, From: now.Truncate(time.Hour).Add(-(maxStatsBuckets - 1) * time.Hour), To: now, Top: DefaultStatsTop},
+		},
+		{
+			name:          "Unknown bucket",
+			query:         dto.StatsQuery{Bucket: "week"},
+			expectedError: "bucket must be hour or day",
+		},
+		{
+			name:          "Empty range",
+			query:         dto.StatsQuery{From: now},
+			expectedError: "from must be before to",
+		},
+		{
+			name:          "Too many buckets",
+			query:         dto.StatsQuery{Bucket: dto.StatsBucketHour, From: now.Add(-maxStatsBuckets * time.Hour)},
+			expectedError: "more than 1000 buckets",
+		},
+		{
+			name:          "Range overflowing a duration",
+			query:         dto.StatsQuery{From: time.Date(1, 1, 2, 0, 0, 0, 0, time.UTC)},
+			expectedError: "more than 1000 buckets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := resolveStatsQuery(tt.query, now)
+
+			if tt.expectedError != "" {
+				assert.ErrorIs(t, err, ErrInvalidStatsQuery)
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, query)
+		})
+	}
+}
+
+func TestGetURLStats(t *testing.T) {
+	now := time.Date(2026, 1, 2, 13, 30, 0, 0, time.UTC)
+	query := dto.StatsQuery{Bucket: dto.StatsBucketHour, From: now.Add(-3 * time.Hour), To: now, Top: 5}
+	dbErr := errors.New("db error")
+
+	tests := []struct {
+		name          string
+		owner         string
+		exists        bool
+		ownerErr      error
+		expectStats   bool
+		statsErr      error
+		expectedError error
+	}{
+		{name: "Owner", owner: testUserID, exists: true, expectStats: true},
+		{name: "Another user's link", owner: "someone-else", exists: true, expectedError: ErrNotLinkOwner},
+		{name: "Link without owner", owner: "", exists: true, expectedError: ErrNotLinkOwner},
+		{name: "Missing link", expectedError: ErrLinkNotFound},
+		{name: "Owner lookup error", ownerErr: dbErr, expectedError: dbErr},
+		{name: "Stats error", owner: testUserID, exists: true, expectStats: true, statsErr: dbErr, expectedError: dbErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mockRepo, ctrl := setupTestService(t)
+			defer ctrl.Finish()
+			s.now = func() time.Time { return now }
+			ctx := context.Background()
+
+			mockRepo.EXPECT().GetURLOwner(ctx, testShortID).Return(tt.owner, tt.exists, tt.ownerErr)
+			if tt.expectStats {
+				mockRepo.EXPECT().
+					GetClickStats(ctx, testShortID, query).
+					Return(dto.ClickStats{
+						TotalClicks:    3,
+						UniqueVisitors: 2,
+						Series: []dto.SeriesPoint{
+							{Start: time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC), Clicks: 3},
+						},
+						TopReferrers:  []dto.ValueCount{{Value: "https://r1.example/", Clicks: 3}},
+						TopUserAgents: []dto.ValueCount{},
+					}, tt.statsErr)
+			}
+
+			stats, err := s.GetURLStats(ctx, testUserID, testShortID, query)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Empty(t, stats.ShortID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, dto.LinkStatsDTO{
+				ShortID: testShortID,
+				Bucket:  dto.StatsBucketHour,
+				From:    query.From,
+				To:      query.To,
+				ClickStats: dto.ClickStats{
+					TotalClicks:    3,
+					UniqueVisitors: 2,
+					// Every bucket of the range has a point.
+					Series: []dto.SeriesPoint{
+						{Start: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC), Clicks: 0},
+						{Start: time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC), Clicks: 3},
+						{Start: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC), Clicks: 0},
+						{Start: time.Date(2026, 1, 2, 13, 0, 0, 0, time.UTC), Clicks: 0},
+					},
+					TopReferrers:  []dto.ValueCount{{Value: "https://r1.example/", Clicks: 3}},
+					TopUserAgents: []dto.ValueCount{},
+				},
+			}, stats)
+		})
+	}
+}
+
+func TestGetURLStatsInvalidQuery(t *testing.T) {
+	// The repository mock expects no call: invalid queries never reach it.
+	s, _, ctrl := setupTestService(t)
+	defer ctrl.Finish()
+
+	_, err := s.GetURLStats(context.Background(), testUserID, testShortID, dto.StatsQuery{Bucket: "minute"})
+
+	assert.ErrorIs(t, err, ErrInvalidStatsQuery)
+}
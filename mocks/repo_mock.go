@@ -12,7 +12,9 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
+	dto "github.com/VladimirAzanza/url-shortener/internal/dto"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,27 +43,58 @@ func (m *MockIURLRepository) EXPECT() *MockIURLRepositoryMockRecorder {
 }
 
 // BatchDeleteURLs mocks base method.
-func (m *MockIURLRepository) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+func (m *MockIURLRepository) BatchDeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchDeleteURLs", ctx, shortURLs)
+	ret := m.ctrl.Call(m, "BatchDeleteURLs", ctx, userID, shortURLs)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BatchDeleteURLs indicates an expected call of BatchDeleteURLs.
-func (mr *MockIURLRepositoryMockRecorder) BatchDeleteURLs(ctx, shortURLs any) *gomock.Call {
+func (mr *MockIURLRepositoryMockRecorder) BatchDeleteURLs(ctx, userID, shortURLs any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchDeleteURLs", reflect.TypeOf((*MockIURLRepository)(nil).BatchDeleteURLs), ctx, shortURLs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchDeleteURLs", reflect.TypeOf((*MockIURLRepository)(nil).BatchDeleteURLs), ctx, userID, shortURLs)
+}
+
+// ExpireURLs mocks base method.
+func (m *MockIURLRepository) ExpireURLs(ctx context.Context, now time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireURLs", ctx, now, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireURLs indicates an expected call of ExpireURLs.
+func (mr *MockIURLRepositoryMockRecorder) ExpireURLs(ctx, now, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireURLs", reflect.TypeOf((*MockIURLRepository)(nil).ExpireURLs), ctx, now, limit)
+}
+
+// GetClickStats mocks base method.
+func (m *MockIURLRepository) GetClickStats(ctx context.Context, shortID string, query dto.StatsQuery) (dto.ClickStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClickStats", ctx, shortID, query)
+	ret0, _ := ret[0].(dto.ClickStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClickStats indicates an expected call of GetClickStats.
+func (mr *MockIURLRepositoryMockRecorder) GetClickStats(ctx, shortID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClickStats", reflect.TypeOf((*MockIURLRepository)(nil).GetClickStats), ctx, shortID, query)
 }
 
 // GetOriginalURL mocks base method.
-func (m *MockIURLRepository) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
+func (m *MockIURLRepository) GetOriginalURL(ctx context.Context, shortID string) (string, time.Time, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOriginalURL", ctx, shortID)
 	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(bool)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(bool)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
 }
 
 // GetOriginalURL indicates an expected call of GetOriginalURL.
@@ -85,6 +118,52 @@ func (mr *MockIURLRepositoryMockRecorder) GetShortIDByOriginalURL(ctx, originalU
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortIDByOriginalURL", reflect.TypeOf((*MockIURLRepository)(nil).GetShortIDByOriginalURL), ctx, originalURL)
 }
 
+// GetURLOwner mocks base method.
+func (m *MockIURLRepository) GetURLOwner(ctx context.Context, shortID string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLOwner", ctx, shortID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetURLOwner indicates an expected call of GetURLOwner.
+func (mr *MockIURLRepositoryMockRecorder) GetURLOwner(ctx, shortID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLOwner", reflect.TypeOf((*MockIURLRepository)(nil).GetURLOwner), ctx, shortID)
+}
+
+// GetUserURLs mocks base method.
+func (m *MockIURLRepository) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserURLs", ctx, userID, cursor, limit)
+	ret0, _ := ret[0].([]dto.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserURLs indicates an expected call of GetUserURLs.
+func (mr *MockIURLRepositoryMockRecorder) GetUserURLs(ctx, userID, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockIURLRepository)(nil).GetUserURLs), ctx, userID, cursor, limit)
+}
+
+// ListURLs mocks base method.
+func (m *MockIURLRepository) ListURLs(ctx context.Context, cursor string, limit int) ([]dto.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListURLs", ctx, cursor, limit)
+	ret0, _ := ret[0].([]dto.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListURLs indicates an expected call of ListURLs.
+func (mr *MockIURLRepositoryMockRecorder) ListURLs(ctx, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListURLs", reflect.TypeOf((*MockIURLRepository)(nil).ListURLs), ctx, cursor, limit)
+}
+
 // Ping mocks base method.
 func (m *MockIURLRepository) Ping(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -99,30 +178,45 @@ func (mr *MockIURLRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockIURLRepository)(nil).Ping), ctx)
 }
 
-// SaveBatchURL mocks base method.
-func (m *MockIURLRepository) SaveBatchURL(ctx context.Context, shortID, originalURL string) error {
+// SaveClicks mocks base method.
+func (m *MockIURLRepository) SaveClicks(ctx context.Context, clicks []dto.ClickEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveBatchURL", ctx, shortID, originalURL)
+	ret := m.ctrl.Call(m, "SaveClicks", ctx, clicks)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveBatchURL indicates an expected call of SaveBatchURL.
-func (mr *MockIURLRepositoryMockRecorder) SaveBatchURL(ctx, shortID, originalURL any) *gomock.Call {
+// SaveClicks indicates an expected call of SaveClicks.
+func (mr *MockIURLRepositoryMockRecorder) SaveClicks(ctx, clicks any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatchURL", reflect.TypeOf((*MockIURLRepository)(nil).SaveBatchURL), ctx, shortID, originalURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveClicks", reflect.TypeOf((*MockIURLRepository)(nil).SaveClicks), ctx, clicks)
 }
 
 // SaveShortID mocks base method.
-func (m *MockIURLRepository) SaveShortID(ctx context.Context, shortID, originalURL string) error {
+func (m *MockIURLRepository) SaveShortID(ctx context.Context, userID, shortID, originalURL string, expiresAt time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveShortID", ctx, shortID, originalURL)
+	ret := m.ctrl.Call(m, "SaveShortID", ctx, userID, shortID, originalURL, expiresAt)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SaveShortID indicates an expected call of SaveShortID.
-func (mr *MockIURLRepositoryMockRecorder) SaveShortID(ctx, shortID, originalURL any) *gomock.Call {
+func (mr *MockIURLRepositoryMockRecorder) SaveShortID(ctx, userID, shortID, originalURL, expiresAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveShortID", reflect.TypeOf((*MockIURLRepository)(nil).SaveShortID), ctx, userID, shortID, originalURL, expiresAt)
+}
+
+// SaveURLBatch mocks base method.
+func (m *MockIURLRepository) SaveURLBatch(ctx context.Context, records []dto.URLRecord) ([]dto.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveURLBatch", ctx, records)
+	ret0, _ := ret[0].([]dto.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveURLBatch indicates an expected call of SaveURLBatch.
+func (mr *MockIURLRepositoryMockRecorder) SaveURLBatch(ctx, records any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveShortID", reflect.TypeOf((*MockIURLRepository)(nil).SaveShortID), ctx, shortID, originalURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveURLBatch", reflect.TypeOf((*MockIURLRepository)(nil).SaveURLBatch), ctx, records)
 }
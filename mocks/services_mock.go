@@ -41,55 +41,55 @@ func (m *MockIURLService) EXPECT() *MockIURLServiceMockRecorder {
 	return m.recorder
 }
 
-// BatchDeleteURLs mocks base method.
-func (m *MockIURLService) BatchDeleteURLs(ctx context.Context, shortURLs []string) error {
+// BatchShortenURLs mocks base method.
+func (m *MockIURLService) BatchShortenURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchDeleteURLs", ctx, shortURLs)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "BatchShortenURLs", ctx, userID, requests)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// BatchDeleteURLs indicates an expected call of BatchDeleteURLs.
-func (mr *MockIURLServiceMockRecorder) BatchDeleteURLs(ctx, shortURLs any) *gomock.Call {
+// BatchShortenURLs indicates an expected call of BatchShortenURLs.
+func (mr *MockIURLServiceMockRecorder) BatchShortenURLs(ctx, userID, requests any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchDeleteURLs", reflect.TypeOf((*MockIURLService)(nil).BatchDeleteURLs), ctx, shortURLs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchShortenURLs", reflect.TypeOf((*MockIURLService)(nil).BatchShortenURLs), ctx, userID, requests)
 }
 
-// BatchShortenURL mocks base method.
-func (m *MockIURLService) BatchShortenURL(ctx context.Context, request dto.BatchRequestDTO) (string, error) {
+// DeleteURLs mocks base method.
+func (m *MockIURLService) DeleteURLs(ctx context.Context, userID string, shortURLs []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchShortenURL", ctx, request)
-	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeleteURLs", ctx, userID, shortURLs)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// BatchShortenURL indicates an expected call of BatchShortenURL.
-func (mr *MockIURLServiceMockRecorder) BatchShortenURL(ctx, request any) *gomock.Call {
+// DeleteURLs indicates an expected call of DeleteURLs.
+func (mr *MockIURLServiceMockRecorder) DeleteURLs(ctx, userID, shortURLs any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchShortenURL", reflect.TypeOf((*MockIURLService)(nil).BatchShortenURL), ctx, request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteURLs", reflect.TypeOf((*MockIURLService)(nil).DeleteURLs), ctx, userID, shortURLs)
 }
 
-// ConcurrentBatchDelete mocks base method.
-func (m *MockIURLService) ConcurrentBatchDelete(ctx context.Context, shortURLs []string) error {
+// ExportURLs mocks base method.
+func (m *MockIURLService) ExportURLs(ctx context.Context, yield func(dto.URLRecord) error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ConcurrentBatchDelete", ctx, shortURLs)
+	ret := m.ctrl.Call(m, "ExportURLs", ctx, yield)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// ConcurrentBatchDelete indicates an expected call of ConcurrentBatchDelete.
-func (mr *MockIURLServiceMockRecorder) ConcurrentBatchDelete(ctx, shortURLs any) *gomock.Call {
+// ExportURLs indicates an expected call of ExportURLs.
+func (mr *MockIURLServiceMockRecorder) ExportURLs(ctx, yield any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConcurrentBatchDelete", reflect.TypeOf((*MockIURLService)(nil).ConcurrentBatchDelete), ctx, shortURLs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportURLs", reflect.TypeOf((*MockIURLService)(nil).ExportURLs), ctx, yield)
 }
 
 // GetOriginalURL mocks base method.
-func (m *MockIURLService) GetOriginalURL(ctx context.Context, shortID string) (string, bool) {
+func (m *MockIURLService) GetOriginalURL(ctx context.Context, shortID string) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOriginalURL", ctx, shortID)
 	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(bool)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
@@ -113,6 +113,52 @@ func (mr *MockIURLServiceMockRecorder) GetStorageType() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageType", reflect.TypeOf((*MockIURLService)(nil).GetStorageType))
 }
 
+// GetURLStats mocks base method.
+func (m *MockIURLService) GetURLStats(ctx context.Context, userID, shortID string, query dto.StatsQuery) (dto.LinkStatsDTO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLStats", ctx, userID, shortID, query)
+	ret0, _ := ret[0].(dto.LinkStatsDTO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLStats indicates an expected call of GetURLStats.
+func (mr *MockIURLServiceMockRecorder) GetURLStats(ctx, userID, shortID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLStats", reflect.TypeOf((*MockIURLService)(nil).GetURLStats), ctx, userID, shortID, query)
+}
+
+// GetUserURLs mocks base method.
+func (m *MockIURLService) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]dto.URLRecord, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserURLs", ctx, userID, cursor, limit)
+	ret0, _ := ret[0].([]dto.URLRecord)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUserURLs indicates an expected call of GetUserURLs.
+func (mr *MockIURLServiceMockRecorder) GetUserURLs(ctx, userID, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockIURLService)(nil).GetUserURLs), ctx, userID, cursor, limit)
+}
+
+// ImportURLs mocks base method.
+func (m *MockIURLService) ImportURLs(ctx context.Context, userID string, requests []dto.BatchRequestDTO) ([]dto.ImportResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportURLs", ctx, userID, requests)
+	ret0, _ := ret[0].([]dto.ImportResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportURLs indicates an expected call of ImportURLs.
+func (mr *MockIURLServiceMockRecorder) ImportURLs(ctx, userID, requests any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportURLs", reflect.TypeOf((*MockIURLService)(nil).ImportURLs), ctx, userID, requests)
+}
+
 // PingDB mocks base method.
 func (m *MockIURLService) PingDB(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -127,32 +173,83 @@ func (mr *MockIURLServiceMockRecorder) PingDB(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingDB", reflect.TypeOf((*MockIURLService)(nil).PingDB), ctx)
 }
 
+// RecordClick mocks base method.
+func (m *MockIURLService) RecordClick(shortID, referrer, userAgent, ip string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RecordClick", shortID, referrer, userAgent, ip)
+}
+
+// RecordClick indicates an expected call of RecordClick.
+func (mr *MockIURLServiceMockRecorder) RecordClick(shortID, referrer, userAgent, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordClick", reflect.TypeOf((*MockIURLService)(nil).RecordClick), shortID, referrer, userAgent, ip)
+}
+
 // ShortenAPIURL mocks base method.
-func (m *MockIURLService) ShortenAPIURL(ctx context.Context, shortenRequest *dto.ShortenRequestDTO) (string, error) {
+func (m *MockIURLService) ShortenAPIURL(ctx context.Context, userID string, shortenRequest *dto.ShortenRequestDTO) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShortenAPIURL", ctx, shortenRequest)
+	ret := m.ctrl.Call(m, "ShortenAPIURL", ctx, userID, shortenRequest)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ShortenAPIURL indicates an expected call of ShortenAPIURL.
-func (mr *MockIURLServiceMockRecorder) ShortenAPIURL(ctx, shortenRequest any) *gomock.Call {
+func (mr *MockIURLServiceMockRecorder) ShortenAPIURL(ctx, userID, shortenRequest any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenAPIURL", reflect.TypeOf((*MockIURLService)(nil).ShortenAPIURL), ctx, shortenRequest)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenAPIURL", reflect.TypeOf((*MockIURLService)(nil).ShortenAPIURL), ctx, userID, shortenRequest)
 }
 
 // ShortenURL mocks base method.
-func (m *MockIURLService) ShortenURL(ctx context.Context, originalURL string) (string, error) {
+func (m *MockIURLService) ShortenURL(ctx context.Context, userID, originalURL string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShortenURL", ctx, originalURL)
+	ret := m.ctrl.Call(m, "ShortenURL", ctx, userID, originalURL)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ShortenURL indicates an expected call of ShortenURL.
-func (mr *MockIURLServiceMockRecorder) ShortenURL(ctx, originalURL any) *gomock.Call {
+func (mr *MockIURLServiceMockRecorder) ShortenURL(ctx, userID, originalURL any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURL", reflect.TypeOf((*MockIURLService)(nil).ShortenURL), ctx, userID, originalURL)
+}
+
+// MockIDGenerator is a mock of IDGenerator interface.
+type MockIDGenerator struct {
+	ctrl     *gomock.Controller
+	recorder *MockIDGeneratorMockRecorder
+	isgomock struct{}
+}
+
+// MockIDGeneratorMockRecorder is the mock recorder for MockIDGenerator.
+type MockIDGeneratorMockRecorder struct {
+	mock *MockIDGenerator
+}
+
+// NewMockIDGenerator creates a new mock instance.
+func NewMockIDGenerator(ctrl *gomock.Controller) *MockIDGenerator {
+	mock := &MockIDGenerator{ctrl: ctrl}
+	mock.recorder = &MockIDGeneratorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIDGenerator) EXPECT() *MockIDGeneratorMockRecorder {
+	return m.recorder
+}
+
+// Generate mocks base method.
+func (m *MockIDGenerator) Generate(ctx context.Context, originalURL string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Generate", ctx, originalURL)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Generate indicates an expected call of Generate.
+func (mr *MockIDGeneratorMockRecorder) Generate(ctx, originalURL any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenURL", reflect.TypeOf((*MockIURLService)(nil).ShortenURL), ctx, originalURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockIDGenerator)(nil).Generate), ctx, originalURL)
 }